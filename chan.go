@@ -0,0 +1,188 @@
+package boltx
+
+import (
+	"context"
+	"encoding"
+	"fmt"
+
+	"github.com/boltdb/bolt"
+)
+
+// Delivery defines an element that has been dequeued into a channel.
+type Delivery struct {
+	// Value holds the raw value of the element.
+	Value []byte
+	// Message holds the element's envelope including the metadata. If the queue has no envelope, only the
+	// payload is set. The delivery attempts are not tracked.
+	Message *Message
+	// Model holds the unmarshaled value, if a factory has been provided.
+	Model encoding.BinaryUnmarshaler
+	// Err holds the error that occurred while dequeuing, unmarshaling or restoring the element.
+	Err error
+}
+
+// ChanOptions defines the options of a delivery channel.
+type ChanOptions struct {
+	// Prefetch defines the number of elements that are dequeued at once. The default is one.
+	Prefetch int
+	// Factory creates the models, the elements are unmarshaled into. If it's nil, only the raw values
+	// are delivered.
+	Factory ModelFactory
+}
+
+// Chan returns a channel that delivers the elements from the front of the queue. The elements are
+// dequeued before they're delivered - up to Prefetch at once. Once the provided context is done, the
+// channel is closed and all dequeued, but not yet delivered elements are put back to the front of the
+// queue - or to the back, if the key scheme doesn't allow keys in front of the queue's head. Elements
+// that can't be put back at all are delivered with an error before the channel is closed, so the channel
+// should be drained until it's closed.
+func (q *Queue) Chan(ctx context.Context, options ChanOptions) <-chan Delivery {
+	pop := func(ctx context.Context, n int) ([][]byte, [][]byte, error) {
+		keys, values := [][]byte(nil), [][]byte(nil)
+		err := popOrWait(ctx, q.session, q.name, PositionFront, n, true, q.ready, q.expiry(), func(tx *bolt.Tx, ks, vs [][]byte) error {
+			for index, key := range ks {
+				if _, err := takeAttempts(tx, q.name, key); err != nil {
+					return err
+				}
+				keys, values = append(keys, key), append(values, append([]byte{}, vs[index]...))
+			}
+			return nil
+		})
+		return keys, values, err
+	}
+	restore := func(values [][]byte) error {
+		return q.db.Update(func(tx *bolt.Tx) error {
+			return restoreValues(tx, q.session, q.name, PositionFront, values, q.keyScheme)
+		})
+	}
+	return stream(ctx, options, q.name, q.envelope, pop, restore)
+}
+
+// ChanFront returns a channel that delivers the elements from the front of the deque. It behaves like
+// Queue.Chan.
+func (d *Deque) ChanFront(ctx context.Context, options ChanOptions) <-chan Delivery {
+	return d.chanAt(ctx, PositionFront, options)
+}
+
+// ChanBack returns a channel that delivers the elements from the back of the deque. It behaves like
+// Queue.Chan, but puts the undelivered elements back to the back of the deque.
+func (d *Deque) ChanBack(ctx context.Context, options ChanOptions) <-chan Delivery {
+	return d.chanAt(ctx, PositionBack, options)
+}
+
+func (d *Deque) chanAt(ctx context.Context, position *Position, options ChanOptions) <-chan Delivery {
+	pop := func(ctx context.Context, n int) ([][]byte, [][]byte, error) {
+		keys, values := [][]byte(nil), [][]byte(nil)
+		err := popOrWait(ctx, d.session, d.name, position, n, true, nil, d.expiry(), func(tx *bolt.Tx, ks, vs [][]byte) error {
+			for index, value := range vs {
+				keys, values = append(keys, ks[index]), append(values, append([]byte{}, value...))
+			}
+			return nil
+		})
+		return keys, values, err
+	}
+	restore := func(values [][]byte) error {
+		return d.db.Update(func(tx *bolt.Tx) error {
+			return restoreValues(tx, d.session, d.name, position, values, d.keyScheme)
+		})
+	}
+	return stream(ctx, options, d.name, d.envelope, pop, restore)
+}
+
+// stream pops values with the provided pop function and sends them to the returned channel until the
+// provided context is done. Values that have been popped, but not sent are passed to the provided
+// restore function. The name of the bucket is used in errors and the envelope flag tells whether the
+// values are wrapped in message envelopes.
+func stream(
+	ctx context.Context,
+	options ChanOptions,
+	name []byte,
+	envelope bool,
+	pop func(ctx context.Context, n int) ([][]byte, [][]byte, error),
+	restore func(values [][]byte) error,
+) <-chan Delivery {
+	prefetch := options.Prefetch
+	if prefetch < 1 {
+		prefetch = 1
+	}
+
+	deliveries := make(chan Delivery)
+	go func() {
+		defer close(deliveries)
+		for {
+			keys, values, err := pop(ctx, prefetch)
+			if err == context.Canceled || err == context.DeadlineExceeded {
+				return
+			}
+			if err != nil {
+				select {
+				case deliveries <- Delivery{Err: err}:
+				case <-ctx.Done():
+				}
+				return
+			}
+
+			for index, value := range values {
+				select {
+				case deliveries <- newDelivery(name, keys[index], value, options.Factory, envelope):
+				case <-ctx.Done():
+					if err := restore(values[index:]); err != nil {
+						deliverUnrestored(deliveries, values[index:], err)
+					}
+					return
+				}
+			}
+		}
+	}()
+	return deliveries
+}
+
+// deliverUnrestored sends the provided values, that couldn't be restored, to the provided channel together
+// with the restore error, so they don't get lost.
+func deliverUnrestored(deliveries chan<- Delivery, values [][]byte, err error) {
+	for _, value := range values {
+		deliveries <- Delivery{Value: value, Err: fmt.Errorf("restoring failed: %v", err)}
+	}
+}
+
+func newDelivery(name, key, value []byte, factory ModelFactory, envelope bool) Delivery {
+	delivery := Delivery{Value: value}
+	message, err := unmarshalMessage(value, envelope)
+	if err != nil {
+		delivery.Err = fmt.Errorf("unmarshaling failed: %v", err)
+		return delivery
+	}
+	delivery.Message = message
+	if factory != nil {
+		delivery.Model = factory()
+		if err := unmarshalElement(delivery.Model, value, envelope); err != nil {
+			delivery.Err = unmarshalError(err, name, key)
+		}
+	}
+	return delivery
+}
+
+// restoreValues puts the provided values back to the provided position in the reverse order, so they
+// end up in the order they've been popped. If the key scheme can't generate keys in front of the head,
+// the values are put to the back in their order instead.
+func restoreValues(tx *bolt.Tx, session *Session, name []byte, position *Position, values [][]byte, scheme KeyScheme) error {
+	for index := len(values) - 1; index >= 0; index-- {
+		_, err := push(tx, name, position, values[index], scheme)
+		if err == ErrKeyExhausted && position == PositionFront {
+			for restored := len(values) - 1; restored > index; restored-- {
+				pop(tx, name, PositionFront)
+			}
+			for _, value := range values {
+				if _, err := push(tx, name, PositionBack, value, scheme); err != nil {
+					return err
+				}
+			}
+			break
+		}
+		if err != nil {
+			return err
+		}
+	}
+	session.broadcastOnCommit(tx, name)
+	return nil
+}
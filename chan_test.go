@@ -0,0 +1,131 @@
+package boltx_test
+
+import (
+	"context"
+	"encoding"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/simia-tech/boltx"
+)
+
+func TestQueueChan(t *testing.T) {
+	db, tearDown := setUpTestDB(t)
+	defer tearDown()
+
+	queue := boltx.NewQueue(db, []byte("test"))
+	require.NoError(t, queue.EnqueueModel(&model{field: "one"}))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	deliveries := queue.Chan(ctx, boltx.ChanOptions{
+		Factory: func() encoding.BinaryUnmarshaler { return &model{} },
+	})
+
+	delivery := <-deliveries
+	require.NoError(t, delivery.Err)
+	assert.Equal(t, "one", string(delivery.Value))
+	assert.Equal(t, &model{field: "one"}, delivery.Model)
+
+	require.NoError(t, queue.EnqueueModel(&model{field: "two"}))
+
+	select {
+	case delivery = <-deliveries:
+		assert.Equal(t, &model{field: "two"}, delivery.Model)
+	case <-time.After(time.Second):
+		t.Fatal("no delivery")
+	}
+}
+
+func TestQueueChanRestoresPrefetchedElements(t *testing.T) {
+	db, tearDown := setUpTestDB(t)
+	defer tearDown()
+
+	queue := boltx.NewQueue(db, []byte("test"))
+	for _, field := range []string{"one", "two", "three", "four"} {
+		require.NoError(t, queue.EnqueueModel(&model{field: field}))
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	deliveries := queue.Chan(ctx, boltx.ChanOptions{Prefetch: 3})
+
+	assert.Equal(t, "one", string((<-deliveries).Value))
+	time.Sleep(10 * time.Millisecond)
+	assert.Equal(t, 1, queue.Size())
+
+	cancel()
+	for range deliveries {
+	}
+
+	assert.Equal(t, 3, queue.Size())
+	value := &model{}
+	for _, field := range []string{"two", "three", "four"} {
+		require.NoError(t, queue.DequeueModel(value))
+		assert.Equal(t, field, value.field)
+	}
+}
+
+func TestQueueChanRestoresWithExhaustedKeySpace(t *testing.T) {
+	db, tearDown := setUpTestDB(t)
+	defer tearDown()
+
+	queue := boltx.NewQueue(db, []byte("test"))
+	queue.SetKeyScheme(boltx.Uint64QueueKeyScheme)
+	for _, field := range []string{"one", "two", "three"} {
+		require.NoError(t, queue.EnqueueModel(&model{field: field}))
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	deliveries := queue.Chan(ctx, boltx.ChanOptions{Prefetch: 3})
+
+	assert.Equal(t, "one", string((<-deliveries).Value))
+	time.Sleep(10 * time.Millisecond)
+	require.NoError(t, queue.EnqueueModel(&model{field: "four"}))
+
+	cancel()
+	time.Sleep(10 * time.Millisecond)
+	for delivery := range deliveries {
+		require.NoError(t, delivery.Err)
+	}
+
+	assert.Equal(t, 3, queue.Size())
+	value := &model{}
+	for _, field := range []string{"four", "two", "three"} {
+		require.NoError(t, queue.DequeueModel(value))
+		assert.Equal(t, field, value.field)
+	}
+}
+
+func TestDequeChan(t *testing.T) {
+	db, tearDown := setUpTestDB(t)
+	defer tearDown()
+
+	deque := boltx.NewDeque(db, []byte("test"))
+	for _, field := range []string{"one", "two", "three"} {
+		require.NoError(t, deque.EnqueueModelBack(&model{field: field}))
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	deliveries := deque.ChanBack(ctx, boltx.ChanOptions{Prefetch: 2})
+
+	assert.Equal(t, "three", string((<-deliveries).Value))
+
+	cancel()
+	for range deliveries {
+	}
+
+	value := &model{}
+	require.NoError(t, deque.DequeueModelBack(value))
+	assert.Equal(t, "two", value.field)
+	require.NoError(t, deque.DequeueModelFront(value))
+	assert.Equal(t, "one", value.field)
+
+	ctx, cancel = context.WithCancel(context.Background())
+	defer cancel()
+	require.NoError(t, deque.EnqueueModelFront(&model{field: "front"}))
+	assert.Equal(t, "front", string((<-deque.ChanFront(ctx, boltx.ChanOptions{})).Value))
+}
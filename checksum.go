@@ -0,0 +1,114 @@
+package boltx
+
+import (
+	"encoding/binary"
+	"fmt"
+	"hash/crc32"
+
+	"github.com/boltdb/bolt"
+)
+
+const checksumVersion byte = 0x01
+
+// checksumHeaderSize holds the size of the version byte and the CRC32C checksum.
+const checksumHeaderSize = 5
+
+var castagnoli = crc32.MakeTable(crc32.Castagnoli)
+
+// ErrChecksumMismatch is returned if a stored value doesn't match its checksum. Queues, deques and
+// functions that take a bucket name set Bucket and Key. Functions that operate on a *bolt.Bucket only set
+// Key, since a bolt bucket doesn't know its name.
+type ErrChecksumMismatch struct {
+	Bucket []byte
+	Key    []byte
+}
+
+func (e *ErrChecksumMismatch) Error() string {
+	return fmt.Sprintf("checksum mismatch of value %q in bucket %q", e.Key, e.Bucket)
+}
+
+type checksumCodec struct {
+	inner Codec
+}
+
+// NewChecksumCodec returns a codec that prefixes the output of the provided inner codec with a CRC32C
+// checksum and verifies it on reads. A corrupt value results in an ErrChecksumMismatch. If the inner
+// codec is nil, BinaryCodec is used.
+func NewChecksumCodec(inner Codec) Codec {
+	if inner == nil {
+		inner = BinaryCodec
+	}
+	return &checksumCodec{inner: inner}
+}
+
+func (cc *checksumCodec) Marshal(v interface{}) ([]byte, error) {
+	data, err := cc.inner.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+
+	value := make([]byte, checksumHeaderSize, checksumHeaderSize+len(data))
+	value[0] = checksumVersion
+	binary.BigEndian.PutUint32(value[1:checksumHeaderSize], crc32.Checksum(data, castagnoli))
+	return append(value, data...), nil
+}
+
+func (cc *checksumCodec) Unmarshal(data []byte, v interface{}) error {
+	if !validChecksum(data) {
+		return &ErrChecksumMismatch{}
+	}
+	return cc.inner.Unmarshal(data[checksumHeaderSize:], v)
+}
+
+// Verify checks the checksums of all values in the bucket with the provided name and returns a mismatch
+// for every corrupt entry. Values are expected to be written with a checksum codec - values without a
+// checksum are reported as corrupt as well. Values of queues and deques with an envelope are verified by
+// their payload. Nested buckets are skipped.
+func Verify(db *bolt.DB, name []byte) ([]*ErrChecksumMismatch, error) {
+	mismatches := []*ErrChecksumMismatch{}
+	err := db.View(func(tx *bolt.Tx) error {
+		bucket := tx.Bucket(name)
+		if bucket == nil {
+			return nil
+		}
+		return bucket.ForEach(func(key, value []byte) error {
+			if _, payload := splitEnvelope(value); value != nil && !validChecksum(payload) {
+				mismatches = append(mismatches, &ErrChecksumMismatch{
+					Bucket: append([]byte{}, name...),
+					Key:    append([]byte{}, key...),
+				})
+			}
+			return nil
+		})
+	})
+	return mismatches, err
+}
+
+func validChecksum(value []byte) bool {
+	if len(value) < checksumHeaderSize || value[0] != checksumVersion {
+		return false
+	}
+	return binary.BigEndian.Uint32(value[1:checksumHeaderSize]) == crc32.Checksum(value[checksumHeaderSize:], castagnoli)
+}
+
+// unmarshalValue unmarshals the provided value with the provided codec into v. A checksum mismatch is
+// returned with the provided bucket name and key, all other errors are wrapped.
+func unmarshalValue(codec Codec, name, key, value []byte, v interface{}) error {
+	if err := codec.Unmarshal(value, v); err != nil {
+		return unmarshalError(err, name, key)
+	}
+	return nil
+}
+
+// unmarshalError returns a checksum mismatch with the provided bucket name and key, if the provided
+// error is one. All other errors are wrapped.
+func unmarshalError(err error, name, key []byte) error {
+	if _, ok := err.(*ErrChecksumMismatch); ok {
+		mismatch := &ErrChecksumMismatch{Key: append([]byte{}, key...)}
+		if name != nil {
+			mismatch.Bucket = append([]byte{}, name...)
+		}
+		return mismatch
+	}
+	return fmt.Errorf("unmarshaling failed: %v", err)
+}
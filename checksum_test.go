@@ -0,0 +1,146 @@
+package boltx_test
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/boltdb/bolt"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/simia-tech/boltx"
+)
+
+func TestChecksumCodec(t *testing.T) {
+	codec := boltx.NewChecksumCodec(boltx.RawCodec)
+
+	data, err := codec.Marshal([]byte("test"))
+	require.NoError(t, err)
+
+	result := []byte(nil)
+	require.NoError(t, codec.Unmarshal(data, &result))
+	assert.Equal(t, "test", string(result))
+
+	data[len(data)-1] ^= 0xff
+	assert.IsType(t, &boltx.ErrChecksumMismatch{}, codec.Unmarshal(data, &result))
+	assert.IsType(t, &boltx.ErrChecksumMismatch{}, codec.Unmarshal([]byte("x"), &result))
+}
+
+func TestChecksumMismatchOnGet(t *testing.T) {
+	db, tearDown := setUpTestDB(t)
+	defer tearDown()
+
+	codec := boltx.NewChecksumCodec(nil)
+	require.NoError(t, boltx.PutValueInBucket(db, []byte("test"), []byte("key"), &model{field: "test"}, codec))
+	corrupt(t, db, []byte("key"))
+
+	_, err := boltx.GetValueFromBucket(db, []byte("test"), []byte("key"), &model{}, codec)
+	assert.Equal(t, &boltx.ErrChecksumMismatch{Bucket: []byte("test"), Key: []byte("key")}, err)
+	assert.Equal(t, `checksum mismatch of value "key" in bucket "test"`, err.Error())
+
+	inTestBucket(t, db, func(bucket *bolt.Bucket) {
+		_, err := boltx.GetValue(bucket, []byte("key"), &model{}, codec)
+		assert.Equal(t, &boltx.ErrChecksumMismatch{Key: []byte("key")}, err)
+
+		_, _, err = boltx.ForEachWithCodec(bucket, &model{}, codec, func(key []byte, value interface{}) (boltx.Action, error) {
+			return boltx.ActionContinue, nil
+		})
+		assert.Equal(t, &boltx.ErrChecksumMismatch{Key: []byte("key")}, err)
+	})
+}
+
+func TestChecksumMismatchOnQueue(t *testing.T) {
+	db, tearDown := setUpTestDB(t)
+	defer tearDown()
+
+	for _, envelope := range []bool{false, true} {
+		queue := boltx.NewQueue(db, []byte("test"))
+		queue.SetCodec(boltx.NewChecksumCodec(boltx.JSONCodec))
+		queue.SetEnvelope(envelope)
+		items := boltx.NewTypedQueue[item](queue)
+		require.NoError(t, items.Enqueue(item{Name: "test"}))
+
+		key := []byte(nil)
+		inTestBucket(t, db, func(bucket *bolt.Bucket) {
+			key, _ = bucket.Cursor().First()
+			key = append([]byte{}, key...)
+		})
+		corrupt(t, db, key)
+
+		_, err := items.Peek()
+		mismatch := &boltx.ErrChecksumMismatch{}
+		require.True(t, errors.As(err, &mismatch))
+		assert.Equal(t, &boltx.ErrChecksumMismatch{Bucket: []byte("test"), Key: key}, mismatch)
+
+		_, err = items.TryDequeue()
+		mismatch = &boltx.ErrChecksumMismatch{}
+		require.True(t, errors.As(err, &mismatch))
+		assert.Equal(t, &boltx.ErrChecksumMismatch{Bucket: []byte("test"), Key: key}, mismatch)
+
+		mismatch = &boltx.ErrChecksumMismatch{}
+		require.True(t, errors.As(queue.DequeueValue(&item{}), &mismatch))
+		assert.Equal(t, &boltx.ErrChecksumMismatch{Bucket: []byte("test"), Key: key}, mismatch)
+
+		assert.Equal(t, 1, queue.Size())
+		require.NoError(t, db.Update(func(tx *bolt.Tx) error {
+			return tx.DeleteBucket([]byte("test"))
+		}))
+		require.NoError(t, queue.Close())
+	}
+}
+
+func TestVerify(t *testing.T) {
+	db, tearDown := setUpTestDB(t)
+	defer tearDown()
+
+	codec := boltx.NewChecksumCodec(nil)
+	for _, key := range []string{"one", "two", "three"} {
+		require.NoError(t, boltx.PutValueInBucket(db, []byte("test"), []byte(key), &model{field: key}, codec))
+	}
+
+	mismatches, err := boltx.Verify(db, []byte("test"))
+	require.NoError(t, err)
+	assert.Empty(t, mismatches)
+
+	corrupt(t, db, []byte("one"))
+	corrupt(t, db, []byte("two"))
+
+	mismatches, err = boltx.Verify(db, []byte("test"))
+	require.NoError(t, err)
+	assert.Equal(t, []*boltx.ErrChecksumMismatch{
+		{Bucket: []byte("test"), Key: []byte("one")},
+		{Bucket: []byte("test"), Key: []byte("two")},
+	}, mismatches)
+
+	queue := boltx.NewQueue(db, []byte("queue"))
+	queue.SetCodec(codec)
+	queue.SetEnvelope(true)
+	require.NoError(t, queue.EnqueueValue(&model{field: "test"}))
+
+	mismatches, err = boltx.Verify(db, []byte("queue"))
+	require.NoError(t, err)
+	assert.Empty(t, mismatches)
+
+	require.NoError(t, db.Update(func(tx *bolt.Tx) error {
+		bucket := tx.Bucket([]byte("queue"))
+		key, value := bucket.Cursor().First()
+		value = append([]byte{}, value...)
+		value[len(value)-1] ^= 0xff
+		return bucket.Put(key, value)
+	}))
+	mismatches, err = boltx.Verify(db, []byte("queue"))
+	require.NoError(t, err)
+	assert.Len(t, mismatches, 1)
+
+	mismatches, err = boltx.Verify(db, []byte("missing"))
+	require.NoError(t, err)
+	assert.Empty(t, mismatches)
+}
+
+func corrupt(tb testing.TB, db *bolt.DB, key []byte) {
+	inTestBucket(tb, db, func(bucket *bolt.Bucket) {
+		value := append([]byte{}, bucket.Get(key)...)
+		value[len(value)-1] ^= 0xff
+		require.NoError(tb, bucket.Put(key, value))
+	})
+}
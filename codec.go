@@ -0,0 +1,122 @@
+package boltx
+
+import (
+	"bytes"
+	"encoding"
+	"encoding/gob"
+	"encoding/json"
+	"fmt"
+)
+
+var (
+	// BinaryCodec marshals values that implement encoding.BinaryMarshaler and unmarshals values that
+	// implement encoding.BinaryUnmarshaler. It's the default codec.
+	BinaryCodec Codec = binaryCodec{}
+
+	// JSONCodec marshals and unmarshals values with encoding/json.
+	JSONCodec Codec = jsonCodec{}
+
+	// GobCodec marshals and unmarshals values with encoding/gob.
+	GobCodec Codec = gobCodec{}
+
+	// RawCodec stores byte slices as they are. It marshals []byte values and unmarshals into *[]byte
+	// values.
+	RawCodec Codec = rawCodec{}
+)
+
+// Codec defines how values are converted to the bytes that are stored in a bucket and back.
+type Codec interface {
+	// Marshal returns the encoded form of the provided value.
+	Marshal(v interface{}) ([]byte, error)
+
+	// Unmarshal decodes the provided data into the provided value. The data is only valid during
+	// the call, so it must be copied if it should be retained.
+	Unmarshal(data []byte, v interface{}) error
+}
+
+type binaryCodec struct{}
+
+func (binaryCodec) Marshal(v interface{}) ([]byte, error) {
+	bm, ok := v.(encoding.BinaryMarshaler)
+	if !ok {
+		return nil, fmt.Errorf("%T has to implement encoding.BinaryMarshaler", v)
+	}
+	return bm.MarshalBinary()
+}
+
+func (binaryCodec) Unmarshal(data []byte, v interface{}) error {
+	bu, ok := v.(encoding.BinaryUnmarshaler)
+	if !ok {
+		return fmt.Errorf("%T has to implement encoding.BinaryUnmarshaler", v)
+	}
+	return bu.UnmarshalBinary(data)
+}
+
+type jsonCodec struct{}
+
+func (jsonCodec) Marshal(v interface{}) ([]byte, error) {
+	return json.Marshal(v)
+}
+
+func (jsonCodec) Unmarshal(data []byte, v interface{}) error {
+	return json.Unmarshal(data, v)
+}
+
+type gobCodec struct{}
+
+func (gobCodec) Marshal(v interface{}) ([]byte, error) {
+	buffer := bytes.Buffer{}
+	if err := gob.NewEncoder(&buffer).Encode(v); err != nil {
+		return nil, err
+	}
+	return buffer.Bytes(), nil
+}
+
+func (gobCodec) Unmarshal(data []byte, v interface{}) error {
+	return gob.NewDecoder(bytes.NewReader(data)).Decode(v)
+}
+
+type rawCodec struct{}
+
+func (rawCodec) Marshal(v interface{}) ([]byte, error) {
+	switch value := v.(type) {
+	case []byte:
+		return value, nil
+	case *[]byte:
+		return *value, nil
+	}
+	return nil, fmt.Errorf("raw codec can't marshal %T", v)
+}
+
+func (rawCodec) Unmarshal(data []byte, v interface{}) error {
+	value, ok := v.(*[]byte)
+	if !ok {
+		return fmt.Errorf("raw codec can't unmarshal into %T", v)
+	}
+	*value = append([]byte{}, data...)
+	return nil
+}
+
+// codecModel adapts a value and a codec to encoding.BinaryMarshaler and encoding.BinaryUnmarshaler, so
+// it can be used with all model functions.
+type codecModel struct {
+	value interface{}
+	codec Codec
+}
+
+func (cm codecModel) MarshalBinary() ([]byte, error) {
+	return cm.codec.Marshal(cm.value)
+}
+
+func (cm codecModel) UnmarshalBinary(data []byte) error {
+	return cm.codec.Unmarshal(data, cm.value)
+}
+
+// modelOf returns the provided value as a model that uses the provided codec. If the codec is nil,
+// BinaryCodec is used.
+func modelOf(v interface{}, codec Codec) codecModel {
+	if codec == nil {
+		codec = BinaryCodec
+	}
+	return codecModel{value: v, codec: codec}
+}
@@ -0,0 +1,120 @@
+package boltx_test
+
+import (
+	"testing"
+
+	"github.com/boltdb/bolt"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/simia-tech/boltx"
+)
+
+type item struct {
+	Name  string
+	Count int
+}
+
+func TestCodecs(t *testing.T) {
+	for _, codec := range []boltx.Codec{boltx.JSONCodec, boltx.GobCodec} {
+		data, err := codec.Marshal(&item{Name: "test", Count: 3})
+		require.NoError(t, err)
+
+		result := &item{}
+		require.NoError(t, codec.Unmarshal(data, result))
+		assert.Equal(t, &item{Name: "test", Count: 3}, result)
+	}
+
+	data, err := boltx.RawCodec.Marshal([]byte("test"))
+	require.NoError(t, err)
+	result := []byte(nil)
+	require.NoError(t, boltx.RawCodec.Unmarshal(data, &result))
+	assert.Equal(t, "test", string(result))
+	_, err = boltx.RawCodec.Marshal("test")
+	assert.Error(t, err)
+
+	data, err = boltx.BinaryCodec.Marshal(&model{field: "test"})
+	require.NoError(t, err)
+	assert.Equal(t, "test", string(data))
+	_, err = boltx.BinaryCodec.Marshal(&item{})
+	assert.Error(t, err)
+	assert.Error(t, boltx.BinaryCodec.Unmarshal(data, &item{}))
+}
+
+func TestPutAndGetValue(t *testing.T) {
+	db, tearDown := setUpTestDB(t)
+	defer tearDown()
+
+	inTestBucket(t, db, func(bucket *bolt.Bucket) {
+		require.NoError(t, boltx.PutValue(bucket, []byte("key"), &item{Name: "test", Count: 1}, boltx.JSONCodec))
+		assert.Equal(t, `{"Name":"test","Count":1}`, string(bucket.Get([]byte("key"))))
+
+		result := &item{}
+		found, err := boltx.GetValue(bucket, []byte("key"), result, boltx.JSONCodec)
+		require.NoError(t, err)
+		assert.True(t, found)
+		assert.Equal(t, &item{Name: "test", Count: 1}, result)
+
+		found, err = boltx.GetValue(bucket, []byte("missing"), result, boltx.JSONCodec)
+		require.NoError(t, err)
+		assert.False(t, found)
+
+		_, err = boltx.GetValue(bucket, []byte("key"), result, boltx.GobCodec)
+		assert.Error(t, err)
+	})
+
+	require.NoError(t, boltx.PutValueInBucket(db, []byte("other"), []byte("key"), &item{Name: "other"}, boltx.GobCodec))
+	result := &item{}
+	found, err := boltx.GetValueFromBucket(db, []byte("other"), []byte("key"), result, boltx.GobCodec)
+	require.NoError(t, err)
+	assert.True(t, found)
+	assert.Equal(t, &item{Name: "other"}, result)
+}
+
+func TestForEachWithCodec(t *testing.T) {
+	db, tearDown := setUpTestDB(t)
+	defer tearDown()
+
+	inTestBucket(t, db, func(bucket *bolt.Bucket) {
+		require.NoError(t, boltx.PutValue(bucket, []byte("one"), &item{Name: "one"}, boltx.JSONCodec))
+		require.NoError(t, boltx.PutValue(bucket, []byte("two"), &item{Name: "two"}, boltx.JSONCodec))
+
+		_, _, err := boltx.ForEachWithCodec(bucket, &item{}, boltx.JSONCodec, func(key []byte, value interface{}) (boltx.Action, error) {
+			value.(*item).Count++
+			return boltx.ActionUpdate, nil
+		})
+		require.NoError(t, err)
+
+		key, value, err := boltx.ForEachWithCodec(bucket, &item{}, boltx.JSONCodec, func(key []byte, value interface{}) (boltx.Action, error) {
+			if value.(*item).Name == "two" {
+				return boltx.ActionReturn, nil
+			}
+			return boltx.ActionContinue, nil
+		})
+		require.NoError(t, err)
+		assert.Equal(t, "two", string(key))
+		assert.Equal(t, &item{Name: "two", Count: 1}, value)
+	})
+}
+
+func TestQueueAndDequeWithCodec(t *testing.T) {
+	db, tearDown := setUpTestDB(t)
+	defer tearDown()
+
+	queue := boltx.NewQueue(db, []byte("queue"))
+	queue.SetCodec(boltx.JSONCodec)
+	require.NoError(t, queue.EnqueueValue(&item{Name: "test", Count: 2}))
+	result := &item{}
+	require.NoError(t, queue.DequeueValue(result))
+	assert.Equal(t, &item{Name: "test", Count: 2}, result)
+
+	deque := boltx.NewDeque(db, []byte("deque"))
+	deque.SetCodec(boltx.RawCodec)
+	require.NoError(t, deque.EnqueueValueBack([]byte("back")))
+	require.NoError(t, deque.EnqueueValueFront([]byte("front")))
+	value := []byte(nil)
+	require.NoError(t, deque.DequeueValueBack(&value))
+	assert.Equal(t, "back", string(value))
+	require.NoError(t, deque.DequeueValueFront(&value))
+	assert.Equal(t, "front", string(value))
+}
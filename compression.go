@@ -0,0 +1,84 @@
+package boltx
+
+import (
+	"bytes"
+	"compress/flate"
+	"errors"
+	"fmt"
+	"io/ioutil"
+)
+
+const (
+	headerUncompressed byte = 0x00
+	headerFlate        byte = 0x01
+)
+
+// DefaultCompressionThreshold defines the size in bytes from which values are compressed by default.
+const DefaultCompressionThreshold = 256
+
+type compressionCodec struct {
+	inner     Codec
+	threshold int
+}
+
+// NewCompressionCodec returns a codec that compresses the output of the provided inner codec with flate,
+// if it's at least threshold bytes long. Each stored value starts with a header byte that tells whether
+// it's compressed, so compressed and uncompressed values can coexist in a bucket. If the inner codec is
+// nil, BinaryCodec is used. A threshold below one selects DefaultCompressionThreshold.
+func NewCompressionCodec(inner Codec, threshold int) Codec {
+	if inner == nil {
+		inner = BinaryCodec
+	}
+	if threshold < 1 {
+		threshold = DefaultCompressionThreshold
+	}
+	return &compressionCodec{inner: inner, threshold: threshold}
+}
+
+func (cc *compressionCodec) Marshal(v interface{}) ([]byte, error) {
+	data, err := cc.inner.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(data) >= cc.threshold {
+		buffer := bytes.Buffer{}
+		buffer.WriteByte(headerFlate)
+		writer, err := flate.NewWriter(&buffer, flate.DefaultCompression)
+		if err != nil {
+			return nil, err
+		}
+		if _, err := writer.Write(data); err != nil {
+			return nil, fmt.Errorf("compressing failed: %v", err)
+		}
+		if err := writer.Close(); err != nil {
+			return nil, fmt.Errorf("compressing failed: %v", err)
+		}
+		// incompressible data is stored as it is
+		if buffer.Len() < len(data)+1 {
+			return buffer.Bytes(), nil
+		}
+	}
+
+	return append([]byte{headerUncompressed}, data...), nil
+}
+
+func (cc *compressionCodec) Unmarshal(data []byte, v interface{}) error {
+	if len(data) == 0 {
+		return errors.New("compression header is missing")
+	}
+
+	switch data[0] {
+	case headerUncompressed:
+		return cc.inner.Unmarshal(data[1:], v)
+	case headerFlate:
+		reader := flate.NewReader(bytes.NewReader(data[1:]))
+		defer reader.Close()
+		decompressed, err := ioutil.ReadAll(reader)
+		if err != nil {
+			return fmt.Errorf("decompressing failed: %v", err)
+		}
+		return cc.inner.Unmarshal(decompressed, v)
+	}
+	return fmt.Errorf("unknown compression header %#x", data[0])
+}
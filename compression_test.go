@@ -0,0 +1,66 @@
+package boltx_test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/boltdb/bolt"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/simia-tech/boltx"
+)
+
+func TestCompressionCodec(t *testing.T) {
+	codec := boltx.NewCompressionCodec(boltx.RawCodec, 16)
+
+	small, err := codec.Marshal([]byte("small"))
+	require.NoError(t, err)
+	assert.Equal(t, append([]byte{0x00}, "small"...), small)
+
+	large := []byte(strings.Repeat("large", 100))
+	compressed, err := codec.Marshal(large)
+	require.NoError(t, err)
+	assert.Equal(t, byte(0x01), compressed[0])
+	assert.True(t, len(compressed) < len(large))
+
+	result := []byte(nil)
+	require.NoError(t, codec.Unmarshal(small, &result))
+	assert.Equal(t, "small", string(result))
+	require.NoError(t, codec.Unmarshal(compressed, &result))
+	assert.Equal(t, large, result)
+
+	assert.Error(t, codec.Unmarshal(nil, &result))
+	assert.Error(t, codec.Unmarshal([]byte{0x02, 'x'}, &result))
+	assert.Error(t, codec.Unmarshal([]byte{0x01, 'x'}, &result))
+}
+
+func TestCompressionCodecWithModels(t *testing.T) {
+	db, tearDown := setUpTestDB(t)
+	defer tearDown()
+
+	codec := boltx.NewCompressionCodec(nil, 0)
+	field := strings.Repeat("test", 100)
+
+	inTestBucket(t, db, func(bucket *bolt.Bucket) {
+		require.NoError(t, boltx.PutValue(bucket, []byte("key"), &model{field: field}, codec))
+		assert.True(t, len(bucket.Get([]byte("key"))) < len(field))
+
+		value := &model{}
+		found, err := boltx.GetValue(bucket, []byte("key"), value, codec)
+		require.NoError(t, err)
+		assert.True(t, found)
+		assert.Equal(t, field, value.field)
+	})
+
+	queue := boltx.NewQueue(db, []byte("queue"))
+	queue.SetCodec(codec)
+	require.NoError(t, queue.EnqueueValue(&model{field: field}))
+	require.NoError(t, queue.EnqueueValue(&model{field: "small"}))
+
+	value := &model{}
+	require.NoError(t, queue.DequeueValue(value))
+	assert.Equal(t, field, value.field)
+	require.NoError(t, queue.DequeueValue(value))
+	assert.Equal(t, "small", value.field)
+}
@@ -0,0 +1,159 @@
+package boltx
+
+import (
+	"context"
+	"encoding"
+	"errors"
+	"sync"
+	"time"
+)
+
+// DefaultConsumerLease defines the lease of the elements that are received by a consumer, if no other
+// lease is specified.
+const DefaultConsumerLease = time.Minute
+
+// DefaultRetryDelay defines the delay of the first retry of a failed element, if no other initial delay
+// is specified.
+const DefaultRetryDelay = time.Second
+
+// HandlerFunc defines a function that handles a dequeued model. If it returns an error, the element is
+// retried later.
+type HandlerFunc func(model encoding.BinaryUnmarshaler) error
+
+// ErrorFunc defines a function that gets called with the errors that occur in the worker with the
+// provided number.
+type ErrorFunc func(worker int, err error)
+
+// RetryPolicy defines the delays between the attempts to handle an element. The first retry is delayed
+// by InitialDelay and every further retry by Multiplier times the previous delay, but at most by
+// MaxDelay. The zero value retries after DefaultRetryDelay and doubles the delay every time.
+type RetryPolicy struct {
+	InitialDelay time.Duration
+	MaxDelay     time.Duration
+	Multiplier   float64
+}
+
+// Delay returns the delay before the next attempt to handle an element that has failed the provided
+// number of times. If no initial delay is set, DefaultRetryDelay is used. If no multiplier is set, the
+// delay is doubled every time.
+func (rp RetryPolicy) Delay(failures int) time.Duration {
+	multiplier := rp.Multiplier
+	if multiplier < 1 {
+		multiplier = 2
+	}
+
+	delay := float64(rp.InitialDelay)
+	if delay <= 0 {
+		delay = float64(DefaultRetryDelay)
+	}
+	for index := 1; index < failures; index++ {
+		delay *= multiplier
+		if rp.MaxDelay > 0 && delay > float64(rp.MaxDelay) {
+			break
+		}
+	}
+	if rp.MaxDelay > 0 && delay > float64(rp.MaxDelay) {
+		return rp.MaxDelay
+	}
+	return time.Duration(delay)
+}
+
+// ConsumerOptions defines the options of a consumer.
+type ConsumerOptions struct {
+	// Workers defines the number of elements that are handled concurrently. The default is one.
+	Workers int
+	// Factory creates the models, the elements are unmarshaled into.
+	Factory ModelFactory
+	// Handler gets called with every unmarshaled model.
+	Handler HandlerFunc
+	// Retry defines the delays before failed elements are delivered again. The default is a delay of
+	// DefaultRetryDelay that doubles with every failure. Once an element reaches the maximum number of
+	// attempts of the queue, it's moved to the dead-letter queue.
+	Retry RetryPolicy
+	// Lease defines how long an element can be handled until it's delivered again. The default is
+	// DefaultConsumerLease.
+	Lease time.Duration
+	// OnError gets called with all errors that occur in the workers - including the ones returned by
+	// the handler.
+	OnError ErrorFunc
+}
+
+// Consume runs the configured number of workers that receive elements from the queue and pass them to
+// the handler. Successfully handled elements are acknowledged, failed ones are retried with the delay
+// of the retry policy. The call blocks until the provided context is done. In that case, no more
+// elements are received, but the ones that are currently handled are finished. If a worker fails
+// irrecoverably, all workers are stopped and the error is returned.
+func (q *Queue) Consume(ctx context.Context, options ConsumerOptions) error {
+	if options.Factory == nil || options.Handler == nil {
+		return errors.New("consumer needs a factory and a handler")
+	}
+	if options.Workers < 1 {
+		options.Workers = 1
+	}
+	if options.Lease <= 0 {
+		options.Lease = DefaultConsumerLease
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	errs := make(chan error, options.Workers)
+	wg := sync.WaitGroup{}
+	for worker := 0; worker < options.Workers; worker++ {
+		wg.Add(1)
+		go func(worker int) {
+			defer wg.Done()
+			if err := q.consume(ctx, worker, options); err != nil {
+				errs <- err
+				cancel()
+			}
+		}(worker)
+	}
+	wg.Wait()
+	close(errs)
+
+	return <-errs
+}
+
+func (q *Queue) consume(ctx context.Context, worker int, options ConsumerOptions) error {
+	for {
+		key, value := []byte(nil), []byte(nil)
+		receipt, attempts, err := q.receive(ctx, options.Lease, func(k, v []byte) error {
+			key, value = k, append([]byte{}, v...)
+			return nil
+		})
+		if err == context.Canceled || err == context.DeadlineExceeded {
+			return nil
+		}
+		if err != nil {
+			report(options.OnError, worker, err)
+			return err
+		}
+
+		if err := q.handle(key, value, options.Factory, options.Handler); err != nil {
+			report(options.OnError, worker, err)
+			if err := q.Retry(receipt, options.Retry.Delay(attempts), err); err != nil {
+				report(options.OnError, worker, err)
+			}
+			continue
+		}
+
+		if err := q.Ack(receipt); err != nil {
+			report(options.OnError, worker, err)
+		}
+	}
+}
+
+func (q *Queue) handle(key, value []byte, factory ModelFactory, handler HandlerFunc) error {
+	model := factory()
+	if err := unmarshalElement(model, value, q.envelope); err != nil {
+		return unmarshalError(err, q.name, key)
+	}
+	return handler(model)
+}
+
+func report(fn ErrorFunc, worker int, err error) {
+	if fn != nil {
+		fn(worker, err)
+	}
+}
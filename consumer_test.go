@@ -0,0 +1,184 @@
+package boltx_test
+
+import (
+	"context"
+	"encoding"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/simia-tech/boltx"
+)
+
+func TestQueueConsume(t *testing.T) {
+	db, tearDown := setUpTestDB(t)
+	defer tearDown()
+
+	queue := boltx.NewQueue(db, []byte("test"))
+	for _, field := range []string{"one", "two", "three", "four", "five"} {
+		require.NoError(t, queue.EnqueueModel(&model{field: field}))
+	}
+
+	values := make(chan string, 5)
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error)
+	go func() {
+		done <- queue.Consume(ctx, boltx.ConsumerOptions{
+			Workers: 3,
+			Factory: func() encoding.BinaryUnmarshaler { return &model{} },
+			Handler: func(value encoding.BinaryUnmarshaler) error {
+				values <- value.(*model).field
+				return nil
+			},
+		})
+	}()
+
+	received := []string{}
+	for len(received) < 5 {
+		received = append(received, <-values)
+	}
+	assert.ElementsMatch(t, []string{"one", "two", "three", "four", "five"}, received)
+
+	cancel()
+	require.NoError(t, <-done)
+
+	assert.Equal(t, 0, queue.Size())
+	assert.Equal(t, 0, queue.InFlightSize())
+}
+
+func TestQueueConsumeWithRetries(t *testing.T) {
+	db, tearDown := setUpTestDB(t)
+	defer tearDown()
+
+	queue := boltx.NewQueue(db, []byte("test"))
+	require.NoError(t, queue.EnqueueModel(&model{field: "test"}))
+
+	mutex, errs := sync.Mutex{}, []error{}
+	values := make(chan string)
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error)
+	go func() {
+		calls := 0
+		done <- queue.Consume(ctx, boltx.ConsumerOptions{
+			Factory: func() encoding.BinaryUnmarshaler { return &model{} },
+			Handler: func(value encoding.BinaryUnmarshaler) error {
+				if calls++; calls < 3 {
+					return errors.New("handler failed")
+				}
+				values <- value.(*model).field
+				return nil
+			},
+			Retry: boltx.RetryPolicy{InitialDelay: 10 * time.Millisecond},
+			OnError: func(worker int, err error) {
+				mutex.Lock()
+				errs = append(errs, err)
+				mutex.Unlock()
+			},
+		})
+	}()
+
+	start := time.Now()
+	assert.Equal(t, "test", <-values)
+	assert.True(t, time.Since(start) >= 30*time.Millisecond)
+
+	cancel()
+	require.NoError(t, <-done)
+
+	mutex.Lock()
+	assert.Len(t, errs, 2)
+	mutex.Unlock()
+	assert.Equal(t, 0, queue.Size())
+}
+
+func TestQueueConsumeIntoDeadLetters(t *testing.T) {
+	db, tearDown := setUpTestDB(t)
+	defer tearDown()
+
+	queue := boltx.NewQueue(db, []byte("test"))
+	queue.SetMaxAttempts(2)
+	require.NoError(t, queue.EnqueueModel(&model{field: "test"}))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error)
+	go func() {
+		done <- queue.Consume(ctx, boltx.ConsumerOptions{
+			Factory: func() encoding.BinaryUnmarshaler { return &model{} },
+			Handler: func(value encoding.BinaryUnmarshaler) error {
+				return errors.New("handler failed")
+			},
+		})
+	}()
+
+	deadLetters := []*boltx.DeadLetter{}
+	for len(deadLetters) == 0 {
+		time.Sleep(10 * time.Millisecond)
+		var err error
+		deadLetters, err = queue.DeadLetters()
+		require.NoError(t, err)
+	}
+
+	cancel()
+	require.NoError(t, <-done)
+
+	assert.Equal(t, "test", string(deadLetters[0].Value))
+	assert.Equal(t, "handler failed", deadLetters[0].Reason)
+	assert.Equal(t, 2, deadLetters[0].Attempts)
+}
+
+func TestQueueConsumeDrainsOnShutdown(t *testing.T) {
+	db, tearDown := setUpTestDB(t)
+	defer tearDown()
+
+	queue := boltx.NewQueue(db, []byte("test"))
+	require.NoError(t, queue.EnqueueModel(&model{field: "test"}))
+
+	started, release := make(chan struct{}), make(chan struct{})
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error)
+	go func() {
+		done <- queue.Consume(ctx, boltx.ConsumerOptions{
+			Factory: func() encoding.BinaryUnmarshaler { return &model{} },
+			Handler: func(value encoding.BinaryUnmarshaler) error {
+				close(started)
+				<-release
+				return nil
+			},
+		})
+	}()
+
+	<-started
+	cancel()
+
+	select {
+	case <-done:
+		t.Fatal("consumer returned before the handler finished")
+	case <-time.After(20 * time.Millisecond):
+	}
+
+	close(release)
+	require.NoError(t, <-done)
+
+	assert.Equal(t, 0, queue.Size())
+	assert.Equal(t, 0, queue.InFlightSize())
+}
+
+func TestRetryPolicyDelay(t *testing.T) {
+	policy := boltx.RetryPolicy{InitialDelay: time.Second, MaxDelay: 5 * time.Second}
+
+	assert.Equal(t, time.Second, policy.Delay(1))
+	assert.Equal(t, 2*time.Second, policy.Delay(2))
+	assert.Equal(t, 4*time.Second, policy.Delay(3))
+	assert.Equal(t, 5*time.Second, policy.Delay(4))
+	assert.Equal(t, 5*time.Second, policy.Delay(100))
+
+	policy = boltx.RetryPolicy{InitialDelay: time.Second, Multiplier: 3}
+	assert.Equal(t, 9*time.Second, policy.Delay(3))
+
+	policy = boltx.RetryPolicy{}
+	assert.Equal(t, boltx.DefaultRetryDelay, policy.Delay(1))
+	assert.Equal(t, 2*boltx.DefaultRetryDelay, policy.Delay(2))
+}
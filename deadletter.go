@@ -0,0 +1,255 @@
+package boltx
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/boltdb/bolt"
+)
+
+// ErrUnknownDeadLetter is returned if no dead letter with the provided id exists.
+var ErrUnknownDeadLetter = errors.New("unknown dead letter")
+
+// DeadLetter defines an element that has been moved out of a queue, because it reached the maximum
+// number of delivery attempts.
+type DeadLetter struct {
+	// ID identifies the dead letter in the dead-letter queue. It's not part of the marshaled data.
+	ID       []byte
+	Value    []byte
+	Reason   string
+	Attempts int
+	FailedAt time.Time
+}
+
+// MarshalBinary implements encoding.BinaryMarshaler.
+func (dl *DeadLetter) MarshalBinary() ([]byte, error) {
+	data := make([]byte, 16+len(dl.Reason)+len(dl.Value))
+	binary.BigEndian.PutUint32(data[0:4], uint32(dl.Attempts))
+	binary.BigEndian.PutUint64(data[4:12], uint64(dl.FailedAt.UnixNano()))
+	binary.BigEndian.PutUint32(data[12:16], uint32(len(dl.Reason)))
+	copy(data[16:], dl.Reason)
+	copy(data[16+len(dl.Reason):], dl.Value)
+	return data, nil
+}
+
+// UnmarshalBinary implements encoding.BinaryUnmarshaler.
+func (dl *DeadLetter) UnmarshalBinary(data []byte) error {
+	if len(data) < 16 {
+		return fmt.Errorf("dead letter is too short (%d bytes)", len(data))
+	}
+	reasonLength := int(binary.BigEndian.Uint32(data[12:16]))
+	if len(data) < 16+reasonLength {
+		return fmt.Errorf("dead letter reason is too short (%d bytes)", len(data)-16)
+	}
+
+	dl.Attempts = int(binary.BigEndian.Uint32(data[0:4]))
+	dl.FailedAt = time.Unix(0, int64(binary.BigEndian.Uint64(data[4:12])))
+	dl.Reason = string(data[16 : 16+reasonLength])
+	dl.Value = append([]byte{}, data[16+reasonLength:]...)
+	return nil
+}
+
+// SetMaxAttempts sets the maximum number of delivery attempts of an element that has been received via
+// ReceiveModel. If an element fails that often, it's moved to the dead-letter queue. A value less than one
+// means no limit. It should be called before the queue is used.
+func (q *Queue) SetMaxAttempts(maxAttempts int) {
+	q.maxAttempts = maxAttempts
+}
+
+// Fail returns the element with the provided receipt to the front of the queue, so it gets delivered
+// again. If the key scheme doesn't allow keys in front of the queue's head, it's returned to the back.
+// If the element reached the maximum number of attempts, it's moved to the dead-letter queue together
+// with the provided reason. If the receipt is unknown, ErrUnknownReceipt is returned.
+func (q *Queue) Fail(receipt Receipt, reason error) error {
+	return q.fail(receipt, time.Time{}, reason)
+}
+
+// Retry behaves like Fail, but puts the element back to the queue once the provided delay has passed.
+// Until then, the element is scheduled like one that has been enqueued via EnqueueModelAfter.
+func (q *Queue) Retry(receipt Receipt, delay time.Duration, reason error) error {
+	return q.fail(receipt, time.Now().Add(delay), reason)
+}
+
+func (q *Queue) fail(receipt Receipt, due time.Time, reason error) error {
+	return q.session.Update(func(tx *bolt.Tx) error {
+		attempts, value, err := popInFlight(tx, q.name, receipt)
+		if err != nil {
+			return err
+		}
+		message := ""
+		if reason != nil {
+			message = reason.Error()
+		}
+		if err := q.release(tx, attempts, value, message, due); err != nil {
+			return err
+		}
+		q.session.broadcastOnCommit(tx, q.name)
+		return nil
+	})
+}
+
+// DeadLetterQueue returns the queue that holds the dead letters of the queue. Its elements can be
+// dequeued into DeadLetter models. It shares the session of the queue, so it doesn't need to be closed
+// on its own. Closing the queue closes it as well - and the other way round.
+func (q *Queue) DeadLetterQueue() *Queue {
+	return newQueue(q.db, deadLetterName(q.name), 0, q.session)
+}
+
+// DeadLetters returns all dead letters of the queue.
+func (q *Queue) DeadLetters() ([]*DeadLetter, error) {
+	deadLetters := []*DeadLetter{}
+	err := q.db.View(func(tx *bolt.Tx) error {
+		bucket := tx.Bucket(deadLetterName(q.name))
+		if bucket == nil {
+			return nil
+		}
+		_, _, err := ForEach(bucket, &DeadLetter{}, func(key []byte, model interface{}) (Action, error) {
+			deadLetter := model.(*DeadLetter)
+			deadLetter.ID = append([]byte{}, key...)
+			deadLetters = append(deadLetters, deadLetter)
+			return ActionContinue, nil
+		})
+		return err
+	})
+	return deadLetters, err
+}
+
+// DeadLetter returns the dead letter with the provided id. If it doesn't exists, ErrUnknownDeadLetter
+// is returned.
+func (q *Queue) DeadLetter(id []byte) (*DeadLetter, error) {
+	deadLetter := &DeadLetter{}
+	found, err := GetModelFromBucket(q.db, deadLetterName(q.name), id, deadLetter)
+	if err != nil {
+		return nil, err
+	}
+	if !found {
+		return nil, ErrUnknownDeadLetter
+	}
+	deadLetter.ID = id
+	return deadLetter, nil
+}
+
+// RequeueDeadLetter removes the dead letter with the provided id and puts its value to the back of
+// the queue. The delivery attempts start again from zero. If the dead letter doesn't exists,
+// ErrUnknownDeadLetter is returned. If the queue is full, ErrFull is returned.
+func (q *Queue) RequeueDeadLetter(id []byte) error {
+	return q.session.Update(func(tx *bolt.Tx) error {
+		bucket := tx.Bucket(deadLetterName(q.name))
+		if bucket == nil {
+			return ErrUnknownDeadLetter
+		}
+
+		deadLetter := &DeadLetter{}
+		found, err := GetModel(bucket, id, deadLetter)
+		if err != nil {
+			return err
+		}
+		if !found {
+			return ErrUnknownDeadLetter
+		}
+
+		if err := bucket.Delete(id); err != nil {
+			return err
+		}
+		return tryPush(tx, q.name, PositionBack, [][]byte{deadLetter.Value}, q.keyScheme, q.capacity, q.session)
+	})
+}
+
+// PurgeDeadLetters removes all dead letters of the queue and returns their number.
+func (q *Queue) PurgeDeadLetters() (int, error) {
+	count := 0
+	err := q.db.Update(func(tx *bolt.Tx) error {
+		name := deadLetterName(q.name)
+		bucket := tx.Bucket(name)
+		if bucket == nil {
+			return nil
+		}
+		count = bucketKeyCount(tx, name)
+		return tx.DeleteBucket(name)
+	})
+	return count, err
+}
+
+// release puts the provided value back to the front of the queue or - if it reached the maximum
+// number of attempts - moves it to the dead-letter queue. If the provided due time lies in the future,
+// the value is scheduled instead of being put back immediately. If the key scheme can't generate a key
+// in front of the queue's head, the value is put to the back of the queue.
+func (q *Queue) release(tx *bolt.Tx, attempts int, value []byte, reason string, due time.Time) error {
+	if q.maxAttempts > 0 && attempts >= q.maxAttempts {
+		return q.bury(tx, attempts, value, reason)
+	}
+
+	if due.After(time.Now()) {
+		key, err := pushScheduled(tx, q.name, due, value)
+		if err != nil {
+			return err
+		}
+		return putAttempts(tx, scheduledName(q.name), key, attempts)
+	}
+
+	key, err := push(tx, q.name, PositionFront, value, q.keyScheme)
+	if err == ErrKeyExhausted {
+		key, err = push(tx, q.name, PositionBack, value, q.keyScheme)
+	}
+	if err != nil {
+		return err
+	}
+	return putAttempts(tx, q.name, key, attempts)
+}
+
+// bury moves the provided value to the dead-letter queue.
+func (q *Queue) bury(tx *bolt.Tx, attempts int, value []byte, reason string) error {
+	deadLetter := &DeadLetter{
+		Value:    value,
+		Reason:   reason,
+		Attempts: attempts,
+		FailedAt: time.Now(),
+	}
+	data, err := deadLetter.MarshalBinary()
+	if err != nil {
+		return err
+	}
+	_, err = push(tx, deadLetterName(q.name), PositionBack, data, Uint64DequeKeyScheme)
+	return err
+}
+
+// deadLetterName returns the name of the bucket that holds the dead letters of the bucket with the
+// provided name.
+func deadLetterName(name []byte) []byte {
+	return append(append([]byte{}, name...), ":dead"...)
+}
+
+// attemptsName returns the name of the bucket that holds the delivery attempts of the values in the
+// bucket with the provided name.
+func attemptsName(name []byte) []byte {
+	return append(append([]byte{}, name...), ":attempts"...)
+}
+
+func putAttempts(tx *bolt.Tx, name, key []byte, attempts int) error {
+	bucket, err := tx.CreateBucketIfNotExists(attemptsName(name))
+	if err != nil {
+		return err
+	}
+
+	value := make([]byte, 4)
+	binary.BigEndian.PutUint32(value, uint32(attempts))
+	return bucket.Put(key, value)
+}
+
+// takeAttempts removes and returns the number of delivery attempts of the value with the provided key.
+func takeAttempts(tx *bolt.Tx, name, key []byte) (int, error) {
+	bucket := tx.Bucket(attemptsName(name))
+	if bucket == nil {
+		return 0, nil
+	}
+
+	value := bucket.Get(key)
+	if value == nil {
+		return 0, nil
+	}
+	attempts := int(binary.BigEndian.Uint32(value))
+
+	return attempts, bucket.Delete(key)
+}
@@ -0,0 +1,164 @@
+package boltx_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/boltdb/bolt"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/simia-tech/boltx"
+)
+
+func TestQueueDeadLetterAfterMaxAttempts(t *testing.T) {
+	db, tearDown := setUpTestDB(t)
+	defer tearDown()
+
+	queue := boltx.NewQueue(db, []byte("test"))
+	queue.SetMaxAttempts(2)
+	require.NoError(t, queue.EnqueueModel(&model{field: "test"}))
+
+	value := &model{}
+	receipt, err := queue.ReceiveModel(context.Background(), value, time.Minute)
+	require.NoError(t, err)
+	require.NoError(t, queue.Fail(receipt, errors.New("first failure")))
+	assert.Equal(t, 1, queue.Size())
+
+	receipt, err = queue.ReceiveModel(context.Background(), value, time.Minute)
+	require.NoError(t, err)
+	require.NoError(t, queue.Fail(receipt, errors.New("second failure")))
+	assert.Equal(t, 0, queue.Size())
+	assert.Equal(t, 0, queue.InFlightSize())
+
+	deadLetters, err := queue.DeadLetters()
+	require.NoError(t, err)
+	require.Len(t, deadLetters, 1)
+	assert.Equal(t, "test", string(deadLetters[0].Value))
+	assert.Equal(t, "second failure", deadLetters[0].Reason)
+	assert.Equal(t, 2, deadLetters[0].Attempts)
+
+	deadLetter, err := queue.DeadLetter(deadLetters[0].ID)
+	require.NoError(t, err)
+	assert.Equal(t, deadLetters[0], deadLetter)
+
+	_, err = queue.DeadLetter([]byte("missing"))
+	assert.Equal(t, boltx.ErrUnknownDeadLetter, err)
+}
+
+func TestQueueDeadLetterAfterExpiredLeases(t *testing.T) {
+	db, tearDown := setUpTestDB(t)
+	defer tearDown()
+
+	queue := boltx.NewQueue(db, []byte("test"))
+	queue.SetMaxAttempts(1)
+	require.NoError(t, queue.EnqueueModel(&model{field: "test"}))
+
+	_, err := queue.ReceiveModel(context.Background(), &model{}, time.Millisecond)
+	require.NoError(t, err)
+	time.Sleep(5 * time.Millisecond)
+
+	assert.Equal(t, boltx.ErrEmpty, queue.TryDequeueModel(&model{}))
+
+	deadLetter := &boltx.DeadLetter{}
+	require.NoError(t, queue.DeadLetterQueue().DequeueModel(deadLetter))
+	assert.Equal(t, "test", string(deadLetter.Value))
+	assert.Equal(t, "lease expired", deadLetter.Reason)
+}
+
+func TestQueueDeadLetterAfterUnmarshalingFailures(t *testing.T) {
+	db, tearDown := setUpTestDB(t)
+	defer tearDown()
+
+	queue := boltx.NewQueue(db, []byte("test"))
+	queue.SetMaxAttempts(2)
+	require.NoError(t, db.Update(func(tx *bolt.Tx) error {
+		return boltx.PushWithKeyScheme(tx, []byte("test"), boltx.PositionBack, []byte("invalid"), boltx.Uint64DequeKeyScheme)
+	}))
+	require.NoError(t, queue.EnqueueModel(&model{field: "valid"}))
+
+	for index := 0; index < 2; index++ {
+		_, err := queue.ReceiveModel(context.Background(), &model{}, time.Minute)
+		assert.EqualError(t, err, "unmarshaling failed: unmarshaling error")
+	}
+
+	deadLetters, err := queue.DeadLetters()
+	require.NoError(t, err)
+	require.Len(t, deadLetters, 1)
+	assert.Equal(t, "invalid", string(deadLetters[0].Value))
+	assert.Equal(t, "unmarshaling failed: unmarshaling error", deadLetters[0].Reason)
+
+	value := &model{}
+	_, err = queue.ReceiveModel(context.Background(), value, time.Minute)
+	require.NoError(t, err)
+	assert.Equal(t, "valid", value.field)
+}
+
+func TestQueueFailWithExhaustedKeySpace(t *testing.T) {
+	db, tearDown := setUpTestDB(t)
+	defer tearDown()
+
+	queue := boltx.NewQueue(db, []byte("test"))
+	queue.SetKeyScheme(boltx.Uint64QueueKeyScheme)
+	require.NoError(t, queue.EnqueueModel(&model{field: "one"}))
+	require.NoError(t, queue.EnqueueModel(&model{field: "two"}))
+
+	first, err := queue.ReceiveModel(context.Background(), &model{}, time.Minute)
+	require.NoError(t, err)
+	_, err = queue.ReceiveModel(context.Background(), &model{}, time.Millisecond)
+	require.NoError(t, err)
+	require.NoError(t, queue.EnqueueModel(&model{field: "three"}))
+
+	require.NoError(t, queue.Fail(first, errors.New("failure")))
+	time.Sleep(5 * time.Millisecond)
+
+	for _, expected := range []string{"three", "one", "two"} {
+		value := &model{}
+		require.NoError(t, queue.DequeueModel(value))
+		assert.Equal(t, expected, value.field)
+	}
+}
+
+func TestQueueRequeueAndPurgeDeadLetters(t *testing.T) {
+	db, tearDown := setUpTestDB(t)
+	defer tearDown()
+
+	queue := boltx.NewQueue(db, []byte("test"))
+	queue.SetMaxAttempts(1)
+	require.NoError(t, queue.EnqueueModel(&model{field: "one"}))
+	require.NoError(t, queue.EnqueueModel(&model{field: "two"}))
+
+	for index := 0; index < 2; index++ {
+		receipt, err := queue.ReceiveModel(context.Background(), &model{}, time.Minute)
+		require.NoError(t, err)
+		require.NoError(t, queue.Nack(receipt))
+	}
+
+	deadLetters, err := queue.DeadLetters()
+	require.NoError(t, err)
+	require.Len(t, deadLetters, 2)
+
+	require.NoError(t, queue.RequeueDeadLetter(deadLetters[0].ID))
+	assert.Equal(t, boltx.ErrUnknownDeadLetter, queue.RequeueDeadLetter(deadLetters[0].ID))
+
+	value := &model{}
+	receipt, err := queue.ReceiveModel(context.Background(), value, time.Minute)
+	require.NoError(t, err)
+	assert.Equal(t, "one", value.field)
+	require.NoError(t, queue.Ack(receipt))
+
+	count, err := queue.PurgeDeadLetters()
+	require.NoError(t, err)
+	assert.Equal(t, 1, count)
+
+	deadLetters, err = queue.DeadLetters()
+	require.NoError(t, err)
+	assert.Empty(t, deadLetters)
+}
+
+func TestDeadLetterUnmarshalingOfInvalidData(t *testing.T) {
+	assert.Error(t, (&boltx.DeadLetter{}).UnmarshalBinary([]byte("short")))
+	assert.Error(t, (&boltx.DeadLetter{}).UnmarshalBinary([]byte{0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 9}))
+}
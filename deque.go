@@ -1,7 +1,10 @@
 package boltx
 
 import (
+	"context"
 	"encoding"
+	"fmt"
+	"time"
 
 	"github.com/boltdb/bolt"
 )
@@ -20,47 +23,283 @@ import (
 //
 //   log.Println(model)
 type Deque struct {
-	db      *bolt.DB
-	name    []byte
-	session *Session
+	db        *bolt.DB
+	name      []byte
+	capacity  int
+	keyScheme KeyScheme
+	codec     Codec
+	envelope  bool
+	session   *Session
 }
 
 // NewDeque initializes a deque in the bucket with the provided name.
 func NewDeque(db *bolt.DB, name []byte) *Deque {
+	return NewBoundedDeque(db, name, 0)
+}
+
+// NewBoundedDeque initializes a deque in the bucket with the provided name that holds at most capacity
+// elements. If the deque is full, enqueue calls block until an element is dequeued. Elements that are put
+// back by a channel are exempt from the limit. A capacity less than one means no limit.
+func NewBoundedDeque(db *bolt.DB, name []byte, capacity int) *Deque {
 	return &Deque{
-		db:      db,
-		name:    name,
-		session: NewSession(db),
+		db:        db,
+		name:      name,
+		capacity:  capacity,
+		keyScheme: Uint64DequeKeyScheme,
+		codec:     BinaryCodec,
+		session:   NewSession(db),
 	}
 }
 
-// EnqueueModelFront puts the provided model to the front of the deque.
+// SetKeyScheme sets the key scheme that generates the keys of the deque's elements. The default is
+// Uint64DequeKeyScheme. It should be called before the deque is used.
+func (d *Deque) SetKeyScheme(scheme KeyScheme) {
+	d.keyScheme = scheme
+}
+
+// SetEnvelope sets whether the elements of the deque are wrapped in a message envelope like the ones of
+// a queue. It's required by EnqueueModelFrontWithTTL and EnqueueModelBackWithTTL. All instances on the
+// same bucket have to use the same setting. It should be called before the deque is used.
+func (d *Deque) SetEnvelope(enabled bool) {
+	d.envelope = enabled
+}
+
+// SetCodec sets the codec that is used by the value functions of the deque. The default is BinaryCodec.
+// It should be called before the deque is used.
+func (d *Deque) SetCodec(codec Codec) {
+	d.codec = codec
+}
+
+// EnqueueValueFront marshals the provided value with the deque's codec and puts it to the front of the
+// deque. If the deque is full, the call blocks until an element is dequeued.
+func (d *Deque) EnqueueValueFront(v interface{}) error {
+	return d.EnqueueModelFront(modelOf(v, d.codec))
+}
+
+// EnqueueValueBack marshals the provided value with the deque's codec and puts it to the back of the
+// deque. If the deque is full, the call blocks until an element is dequeued.
+func (d *Deque) EnqueueValueBack(v interface{}) error {
+	return d.EnqueueModelBack(modelOf(v, d.codec))
+}
+
+// DequeueValueFront gets the value from the front of the deque, unmarshals it with the deque's codec
+// into v and removes it. If the deque is empty the call blocks until an element is enqueued.
+func (d *Deque) DequeueValueFront(v interface{}) error {
+	return d.DequeueModelFront(modelOf(v, d.codec))
+}
+
+// DequeueValueBack gets the value from the back of the deque, unmarshals it with the deque's codec
+// into v and removes it. If the deque is empty the call blocks until an element is enqueued.
+func (d *Deque) DequeueValueBack(v interface{}) error {
+	return d.DequeueModelBack(modelOf(v, d.codec))
+}
+
+// EnqueueModelFront puts the provided model to the front of the deque. If the deque is full, the
+// call blocks until an element is dequeued.
 func (d *Deque) EnqueueModelFront(model encoding.BinaryMarshaler) error {
-	return d.session.Update(func(tx *bolt.Tx) error {
-		return PushModelAndSignal(tx, d.name, PositionFront, model, DefaultUint64DequeKey, d.session)
-	})
+	return d.enqueueModels(context.Background(), PositionFront, []encoding.BinaryMarshaler{model}, true)
 }
 
-// EnqueueModelBack puts the provided model to the back of the deque.
+// EnqueueModelBack puts the provided model to the back of the deque. If the deque is full, the
+// call blocks until an element is dequeued.
 func (d *Deque) EnqueueModelBack(model encoding.BinaryMarshaler) error {
-	return d.session.Update(func(tx *bolt.Tx) error {
-		return PushModelAndSignal(tx, d.name, PositionBack, model, DefaultUint64DequeKey, d.session)
-	})
+	return d.enqueueModels(context.Background(), PositionBack, []encoding.BinaryMarshaler{model}, true)
+}
+
+// EnqueueModelFrontContext behaves like EnqueueModelFront, but stops waiting for free space if the
+// provided context is done. In that case, the context's error is returned.
+func (d *Deque) EnqueueModelFrontContext(ctx context.Context, model encoding.BinaryMarshaler) error {
+	return d.enqueueModels(ctx, PositionFront, []encoding.BinaryMarshaler{model}, true)
+}
+
+// EnqueueModelBackContext behaves like EnqueueModelBack, but stops waiting for free space if the
+// provided context is done. In that case, the context's error is returned.
+func (d *Deque) EnqueueModelBackContext(ctx context.Context, model encoding.BinaryMarshaler) error {
+	return d.enqueueModels(ctx, PositionBack, []encoding.BinaryMarshaler{model}, true)
+}
+
+// TryEnqueueModelFront puts the provided model to the front of the deque. If the deque is full,
+// ErrFull is returned immediately.
+func (d *Deque) TryEnqueueModelFront(model encoding.BinaryMarshaler) error {
+	return d.enqueueModels(context.Background(), PositionFront, []encoding.BinaryMarshaler{model}, false)
+}
+
+// TryEnqueueModelBack puts the provided model to the back of the deque. If the deque is full,
+// ErrFull is returned immediately.
+func (d *Deque) TryEnqueueModelBack(model encoding.BinaryMarshaler) error {
+	return d.enqueueModels(context.Background(), PositionBack, []encoding.BinaryMarshaler{model}, false)
+}
+
+// EnqueueModelsFront puts all the provided models to the front of the deque in a single transaction.
+// The models are inserted one after another, so the last model ends up at the front. If the deque
+// hasn't enough space left for all models, the call blocks until enough elements are dequeued.
+func (d *Deque) EnqueueModelsFront(models []encoding.BinaryMarshaler) error {
+	return d.enqueueModels(context.Background(), PositionFront, models, true)
+}
+
+// EnqueueModelsBack puts all the provided models to the back of the deque in a single transaction.
+// If the deque hasn't enough space left for all models, the call blocks until enough elements are
+// dequeued.
+func (d *Deque) EnqueueModelsBack(models []encoding.BinaryMarshaler) error {
+	return d.enqueueModels(context.Background(), PositionBack, models, true)
+}
+
+func (d *Deque) enqueueModels(
+	ctx context.Context,
+	position *Position,
+	models []encoding.BinaryMarshaler,
+	wait bool,
+) error {
+	return pushModelsOrWait(ctx, d.session, d.name, position, envelop(models, d.envelope), d.keyScheme, d.capacity, wait)
+}
+
+// EnqueueModelFrontTx puts the provided model to the front of the deque within the provided transaction.
+// Waiting consumers are woken up once the transaction is committed. If the deque is full, ErrFull is
+// returned immediately.
+func (d *Deque) EnqueueModelFrontTx(tx *bolt.Tx, model encoding.BinaryMarshaler) error {
+	return d.enqueueModelTx(tx, PositionFront, model)
+}
+
+// EnqueueModelBackTx puts the provided model to the back of the deque within the provided transaction.
+// Waiting consumers are woken up once the transaction is committed. If the deque is full, ErrFull is
+// returned immediately.
+func (d *Deque) EnqueueModelBackTx(tx *bolt.Tx, model encoding.BinaryMarshaler) error {
+	return d.enqueueModelTx(tx, PositionBack, model)
+}
+
+func (d *Deque) enqueueModelTx(tx *bolt.Tx, position *Position, model encoding.BinaryMarshaler) error {
+	value, err := envelopeOf(model, d.envelope).MarshalBinary()
+	if err != nil {
+		return fmt.Errorf("marshaling failed: %v", err)
+	}
+	return tryPush(tx, d.name, position, [][]byte{value}, d.keyScheme, d.capacity, d.session)
 }
 
 // DequeueModelFront gets the value from the front of the deque, unmarshals it into the provided
 // model and removes it. If the deque is empty the call blocks until an element is enqueued.
 func (d *Deque) DequeueModelFront(model encoding.BinaryUnmarshaler) error {
-	return d.session.Update(func(tx *bolt.Tx) error {
-		return PopModelOrWait(tx, d.name, PositionFront, model, d.session)
-	})
+	return d.DequeueModelFrontContext(context.Background(), model)
 }
 
 // DequeueModelBack gets the value from the back of the deque, unmarshals it into the provided
 // model and removes it. If the deque is empty the call blocks until an element is enqueued.
 func (d *Deque) DequeueModelBack(model encoding.BinaryUnmarshaler) error {
-	return d.session.Update(func(tx *bolt.Tx) error {
-		return PopModelOrWait(tx, d.name, PositionBack, model, d.session)
+	return d.DequeueModelBackContext(context.Background(), model)
+}
+
+// DequeueModelFrontContext behaves like DequeueModelFront, but stops waiting for an element if the
+// provided context is done. In that case, the context's error is returned.
+func (d *Deque) DequeueModelFrontContext(ctx context.Context, model encoding.BinaryUnmarshaler) error {
+	_, err := d.dequeueModels(ctx, PositionFront, 1, true, factoryOf(model))
+	return err
+}
+
+// DequeueModelBackContext behaves like DequeueModelBack, but stops waiting for an element if the
+// provided context is done. In that case, the context's error is returned.
+func (d *Deque) DequeueModelBackContext(ctx context.Context, model encoding.BinaryUnmarshaler) error {
+	_, err := d.dequeueModels(ctx, PositionBack, 1, true, factoryOf(model))
+	return err
+}
+
+// DequeueModelsFront gets up to n values from the front of the deque, unmarshals them into models
+// created by the provided factory and removes them in a single transaction. If the deque is empty
+// the call blocks until an element is enqueued.
+func (d *Deque) DequeueModelsFront(n int, factory ModelFactory) ([]encoding.BinaryUnmarshaler, error) {
+	return d.dequeueModels(context.Background(), PositionFront, n, true, factory)
+}
+
+// DequeueModelsBack gets up to n values from the back of the deque, unmarshals them into models
+// created by the provided factory and removes them in a single transaction. If the deque is empty
+// the call blocks until an element is enqueued.
+func (d *Deque) DequeueModelsBack(n int, factory ModelFactory) ([]encoding.BinaryUnmarshaler, error) {
+	return d.dequeueModels(context.Background(), PositionBack, n, true, factory)
+}
+
+// DequeueModelsFrontContext behaves like DequeueModelsFront, but stops waiting for an element if the
+// provided context is done. In that case, the context's error is returned.
+func (d *Deque) DequeueModelsFrontContext(ctx context.Context, n int, factory ModelFactory) ([]encoding.BinaryUnmarshaler, error) {
+	return d.dequeueModels(ctx, PositionFront, n, true, factory)
+}
+
+// DequeueModelsBackContext behaves like DequeueModelsBack, but stops waiting for an element if the
+// provided context is done. In that case, the context's error is returned.
+func (d *Deque) DequeueModelsBackContext(ctx context.Context, n int, factory ModelFactory) ([]encoding.BinaryUnmarshaler, error) {
+	return d.dequeueModels(ctx, PositionBack, n, true, factory)
+}
+
+func (d *Deque) dequeueModels(
+	ctx context.Context,
+	position *Position,
+	n int,
+	wait bool,
+	factory ModelFactory,
+) ([]encoding.BinaryUnmarshaler, error) {
+	models := []encoding.BinaryUnmarshaler(nil)
+	err := popOrWait(ctx, d.session, d.name, position, n, wait, nil, d.expiry(), func(tx *bolt.Tx, keys, values [][]byte) (err error) {
+		models, err = unmarshalModels(d.name, keys, values, factory, d.envelope)
+		return
+	})
+	return models, err
+}
+
+// DequeueModelFrontTx gets the value from the front of the deque, unmarshals it into the provided model
+// and removes it within the provided transaction. If the deque is empty, ErrEmpty is returned
+// immediately.
+func (d *Deque) DequeueModelFrontTx(tx *bolt.Tx, model encoding.BinaryUnmarshaler) error {
+	return d.dequeueModelTx(tx, PositionFront, model)
+}
+
+// DequeueModelBackTx gets the value from the back of the deque, unmarshals it into the provided model
+// and removes it within the provided transaction. If the deque is empty, ErrEmpty is returned
+// immediately.
+func (d *Deque) DequeueModelBackTx(tx *bolt.Tx, model encoding.BinaryUnmarshaler) error {
+	return d.dequeueModelTx(tx, PositionBack, model)
+}
+
+func (d *Deque) dequeueModelTx(tx *bolt.Tx, position *Position, model encoding.BinaryUnmarshaler) error {
+	key, value, dropped, err := popLive(tx, d.name, position, time.Now(), d.expiry())
+	if err != nil {
+		return err
+	}
+	if dropped > 0 || key != nil {
+		d.session.signalFreeOnCommit(tx, d.name)
+	}
+	if key == nil {
+		return ErrEmpty
+	}
+	if err := unmarshalElement(model, value, d.envelope); err != nil {
+		return unmarshalError(err, d.name, key)
+	}
+	return nil
+}
+
+// TryDequeueModelFront gets the value from the front of the deque, unmarshals it into the provided
+// model and removes it. If the deque is empty, ErrEmpty is returned immediately.
+func (d *Deque) TryDequeueModelFront(model encoding.BinaryUnmarshaler) error {
+	_, err := d.dequeueModels(context.Background(), PositionFront, 1, false, factoryOf(model))
+	return err
+}
+
+// TryDequeueModelBack gets the value from the back of the deque, unmarshals it into the provided
+// model and removes it. If the deque is empty, ErrEmpty is returned immediately.
+func (d *Deque) TryDequeueModelBack(model encoding.BinaryUnmarshaler) error {
+	_, err := d.dequeueModels(context.Background(), PositionBack, 1, false, factoryOf(model))
+	return err
+}
+
+// PeekModelFront gets the value from the front of the deque and unmarshals it into the provided
+// model without removing it. If the deque is empty, ErrEmpty is returned.
+func (d *Deque) PeekModelFront(model encoding.BinaryUnmarshaler) error {
+	return d.db.View(func(tx *bolt.Tx) error {
+		return peekModel(tx, d.name, PositionFront, model, d.envelope)
+	})
+}
+
+// PeekModelBack gets the value from the back of the deque and unmarshals it into the provided
+// model without removing it. If the deque is empty, ErrEmpty is returned.
+func (d *Deque) PeekModelBack(model encoding.BinaryUnmarshaler) error {
+	return d.db.View(func(tx *bolt.Tx) error {
+		return peekModel(tx, d.name, PositionBack, model, d.envelope)
 	})
 }
 
@@ -68,3 +307,9 @@ func (d *Deque) DequeueModelBack(model encoding.BinaryUnmarshaler) error {
 func (d *Deque) Size() int {
 	return BucketSize(d.db, d.name)
 }
+
+// Close closes the deque. All calls that are waiting for an element or for free space return
+// ErrClosed, as well as all further enqueue and dequeue calls.
+func (d *Deque) Close() error {
+	return d.session.Close()
+}
@@ -1,9 +1,12 @@
 package boltx_test
 
 import (
+	"context"
+	"encoding"
 	"testing"
 	"time"
 
+	"github.com/boltdb/bolt"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 
@@ -100,3 +103,145 @@ func TestDequeWithInvalidBucketName(t *testing.T) {
 
 	assert.Error(t, deque.EnqueueModelFront(&model{field: "test"}))
 }
+
+func TestDequeDequeueWithContext(t *testing.T) {
+	db, tearDown := setUpTestDB(t)
+	defer tearDown()
+
+	deque := boltx.NewDeque(db, []byte("test"))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	errs := make(chan error)
+	go func() {
+		errs <- deque.DequeueModelBackContext(ctx, &model{})
+	}()
+
+	time.Sleep(20 * time.Millisecond)
+	cancel()
+	assert.Equal(t, context.Canceled, <-errs)
+
+	require.NoError(t, deque.EnqueueModelBack(&model{field: "test"}))
+
+	value := &model{}
+	require.NoError(t, deque.DequeueModelFrontContext(context.Background(), value))
+	assert.Equal(t, &model{field: "test"}, value)
+}
+
+func TestDequeTryDequeue(t *testing.T) {
+	db, tearDown := setUpTestDB(t)
+	defer tearDown()
+
+	deque := boltx.NewDeque(db, []byte("test"))
+
+	value := &model{}
+	assert.Equal(t, boltx.ErrEmpty, deque.TryDequeueModelFront(value))
+	assert.Equal(t, boltx.ErrEmpty, deque.TryDequeueModelBack(value))
+
+	require.NoError(t, deque.EnqueueModelBack(&model{field: "one"}))
+	require.NoError(t, deque.EnqueueModelBack(&model{field: "two"}))
+
+	require.NoError(t, deque.TryDequeueModelBack(value))
+	assert.Equal(t, "two", value.field)
+	require.NoError(t, deque.TryDequeueModelFront(value))
+	assert.Equal(t, "one", value.field)
+
+	assert.Equal(t, boltx.ErrEmpty, deque.TryDequeueModelFront(value))
+}
+
+func TestDequeDequeueInTransaction(t *testing.T) {
+	db, tearDown := setUpTestDB(t)
+	defer tearDown()
+
+	deque := boltx.NewDeque(db, []byte("test"))
+	require.NoError(t, db.Update(func(tx *bolt.Tx) error {
+		require.NoError(t, deque.EnqueueModelBackTx(tx, &model{field: "one"}))
+		require.NoError(t, deque.EnqueueModelBackTx(tx, &model{field: "two"}))
+		return deque.EnqueueModelFrontTx(tx, &model{field: "zero"})
+	}))
+
+	front, back := &model{}, &model{}
+	require.NoError(t, db.Update(func(tx *bolt.Tx) error {
+		require.NoError(t, deque.DequeueModelFrontTx(tx, front))
+		return deque.DequeueModelBackTx(tx, back)
+	}))
+	assert.Equal(t, &model{field: "zero"}, front)
+	assert.Equal(t, &model{field: "two"}, back)
+	assert.Equal(t, 1, deque.Size())
+}
+
+func TestDequePeek(t *testing.T) {
+	db, tearDown := setUpTestDB(t)
+	defer tearDown()
+
+	deque := boltx.NewDeque(db, []byte("test"))
+
+	value := &model{}
+	assert.Equal(t, boltx.ErrEmpty, deque.PeekModelFront(value))
+	assert.Equal(t, boltx.ErrEmpty, deque.PeekModelBack(value))
+
+	require.NoError(t, deque.EnqueueModelBack(&model{field: "one"}))
+	require.NoError(t, deque.EnqueueModelBack(&model{field: "two"}))
+
+	require.NoError(t, deque.PeekModelFront(value))
+	assert.Equal(t, "one", value.field)
+	require.NoError(t, deque.PeekModelBack(value))
+	assert.Equal(t, "two", value.field)
+	assert.Equal(t, 2, deque.Size())
+
+	require.NoError(t, boltx.PutInBucket(db, []byte("test"), []byte("test"), []byte("invalid")))
+	assert.Error(t, deque.PeekModelFront(value))
+}
+
+func TestDequeBatchQueueing(t *testing.T) {
+	db, tearDown := setUpTestDB(t)
+	defer tearDown()
+
+	deque := boltx.NewDeque(db, []byte("test"))
+
+	require.NoError(t, deque.EnqueueModelsBack([]encoding.BinaryMarshaler{&model{field: "three"}, &model{field: "four"}}))
+	require.NoError(t, deque.EnqueueModelsFront([]encoding.BinaryMarshaler{&model{field: "two"}, &model{field: "one"}}))
+	assert.Equal(t, 4, deque.Size())
+
+	factory := func() encoding.BinaryUnmarshaler { return &model{} }
+
+	models, err := deque.DequeueModelsFront(2, factory)
+	require.NoError(t, err)
+	assert.Equal(t, []encoding.BinaryUnmarshaler{&model{field: "one"}, &model{field: "two"}}, models)
+
+	models, err = deque.DequeueModelsBackContext(context.Background(), 2, factory)
+	require.NoError(t, err)
+	assert.Equal(t, []encoding.BinaryUnmarshaler{&model{field: "four"}, &model{field: "three"}}, models)
+
+	require.NoError(t, boltx.PutInBucket(db, []byte("test"), []byte("test"), []byte("invalid")))
+	_, err = deque.DequeueModelsBack(1, factory)
+	assert.Error(t, err)
+}
+
+func TestBoundedDeque(t *testing.T) {
+	db, tearDown := setUpTestDB(t)
+	defer tearDown()
+
+	deque := boltx.NewBoundedDeque(db, []byte("test"), 1)
+
+	require.NoError(t, deque.TryEnqueueModelBack(&model{field: "one"}))
+	assert.Equal(t, boltx.ErrFull, deque.TryEnqueueModelFront(&model{field: "two"}))
+	assert.Equal(t, boltx.ErrFull, deque.TryEnqueueModelBack(&model{field: "two"}))
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	assert.Equal(t, context.DeadlineExceeded, deque.EnqueueModelFrontContext(ctx, &model{field: "two"}))
+
+	errs := make(chan error)
+	go func() {
+		errs <- deque.EnqueueModelBackContext(context.Background(), &model{field: "two"})
+	}()
+
+	time.Sleep(20 * time.Millisecond)
+	value := &model{}
+	require.NoError(t, deque.TryDequeueModelFront(value))
+	assert.Equal(t, "one", value.field)
+
+	require.NoError(t, <-errs)
+	require.NoError(t, deque.DequeueModelBack(value))
+	assert.Equal(t, "two", value.field)
+}
@@ -0,0 +1,258 @@
+package boltx
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"errors"
+	"fmt"
+
+	"github.com/boltdb/bolt"
+)
+
+const encryptionVersion byte = 0x01
+
+// DefaultReencryptBatchSize defines the number of values that are re-encrypted in a single transaction
+// by default.
+const DefaultReencryptBatchSize = 100
+
+// ErrUnknownKey is returned by a key provider if there is no key with the requested id.
+var ErrUnknownKey = errors.New("unknown key")
+
+// errNotEncrypted is returned if a value doesn't start with an encryption header.
+var errNotEncrypted = errors.New("encryption header is invalid")
+
+// KeyProvider defines the source of the keys used by the encryption codec. The keys have to be 16, 24 or
+// 32 bytes long to select AES-128, AES-192 or AES-256.
+type KeyProvider interface {
+	// CurrentKey returns the id and the key that new values are encrypted with.
+	CurrentKey() (string, []byte, error)
+
+	// Key returns the key with the provided id. If there is no such key, ErrUnknownKey should be returned.
+	Key(id string) ([]byte, error)
+}
+
+type staticKeyProvider struct {
+	currentID string
+	keys      map[string][]byte
+}
+
+// NewStaticKeyProvider returns a key provider that holds the provided keys in memory. New values are
+// encrypted with the key of the provided current id.
+func NewStaticKeyProvider(currentID string, keys map[string][]byte) KeyProvider {
+	return &staticKeyProvider{currentID: currentID, keys: keys}
+}
+
+func (skp *staticKeyProvider) CurrentKey() (string, []byte, error) {
+	key, err := skp.Key(skp.currentID)
+	return skp.currentID, key, err
+}
+
+func (skp *staticKeyProvider) Key(id string) ([]byte, error) {
+	key, ok := skp.keys[id]
+	if !ok {
+		return nil, ErrUnknownKey
+	}
+	return key, nil
+}
+
+// EncryptionCodec encrypts the output of an inner codec with AES-GCM. Each stored value contains the id
+// of the key it has been encrypted with, so keys can be rotated. Values that have been encrypted with an
+// older key can still be read as long as the key provider knows the key.
+//
+// A codec doesn't know where a value is stored, so the bucket and key are not authenticated. A value
+// can be copied to another key and is still decrypted there. To tie values to a bucket, a context like
+// the bucket name can be set via WithContext.
+type EncryptionCodec struct {
+	inner   Codec
+	keys    KeyProvider
+	context []byte
+}
+
+// NewEncryptionCodec returns a codec that encrypts the output of the provided inner codec with the
+// current key of the provided key provider. If the inner codec is nil, BinaryCodec is used.
+func NewEncryptionCodec(inner Codec, keys KeyProvider) *EncryptionCodec {
+	if inner == nil {
+		inner = BinaryCodec
+	}
+	return &EncryptionCodec{inner: inner, keys: keys}
+}
+
+// WithContext returns a copy of the codec that authenticates the provided context along with each value.
+// A value can only be decrypted with the same context it has been encrypted with.
+func (ec *EncryptionCodec) WithContext(context []byte) *EncryptionCodec {
+	return &EncryptionCodec{inner: ec.inner, keys: ec.keys, context: append([]byte{}, context...)}
+}
+
+// Marshal implements Codec.
+func (ec *EncryptionCodec) Marshal(v interface{}) ([]byte, error) {
+	data, err := ec.inner.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	return ec.encrypt(data)
+}
+
+// Unmarshal implements Codec.
+func (ec *EncryptionCodec) Unmarshal(data []byte, v interface{}) error {
+	_, plaintext, err := ec.decrypt(data)
+	if err != nil {
+		return err
+	}
+	return ec.inner.Unmarshal(plaintext, v)
+}
+
+// Reencrypt encrypts all values in the bucket with the provided name, that haven't been encrypted with
+// the current key, with the current key. The values are processed in batches of the provided size, each
+// in its own transaction, so writers aren't blocked for the whole pass. A batch size below one selects
+// DefaultReencryptBatchSize. Values of queues and deques with an envelope are re-encrypted in their
+// envelope. Nested buckets and values that don't start with an encryption header - like plain values -
+// are skipped and left as they are. A value that has an encryption header, but can't be decrypted,
+// aborts the pass. The number of re-encrypted values is returned.
+func (ec *EncryptionCodec) Reencrypt(db *bolt.DB, name []byte, batchSize int) (int, error) {
+	if batchSize < 1 {
+		batchSize = DefaultReencryptBatchSize
+	}
+
+	currentID, _, err := ec.keys.CurrentKey()
+	if err != nil {
+		return 0, fmt.Errorf("getting current key failed: %v", err)
+	}
+
+	count := 0
+	last := []byte(nil)
+	for done := false; !done; {
+		batchCount := 0
+		err := db.Update(func(tx *bolt.Tx) error {
+			bucket := tx.Bucket(name)
+			if bucket == nil {
+				done = true
+				return nil
+			}
+
+			cursor := bucket.Cursor()
+			key, value := cursor.First()
+			if last != nil {
+				if key, value = cursor.Seek(last); bytes.Equal(key, last) {
+					key, value = cursor.Next()
+				}
+			}
+
+			keys, values := [][]byte{}, [][]byte{}
+			for ; key != nil && len(keys) < batchSize; key, value = cursor.Next() {
+				if value == nil {
+					continue
+				}
+				keys = append(keys, append([]byte{}, key...))
+				values = append(values, append([]byte{}, value...))
+			}
+			if len(keys) < batchSize {
+				done = true
+			}
+
+			for index, key := range keys {
+				envelope, data := splitEnvelope(values[index])
+				id, plaintext, err := ec.decrypt(data)
+				if err == errNotEncrypted {
+					continue
+				}
+				if err != nil {
+					return fmt.Errorf("decrypting value %q failed: %v", key, err)
+				}
+				if id == currentID {
+					continue
+				}
+				ciphertext, err := ec.encrypt(plaintext)
+				if err != nil {
+					return err
+				}
+				if err := bucket.Put(key, append(append([]byte{}, envelope...), ciphertext...)); err != nil {
+					return err
+				}
+				batchCount++
+			}
+			if len(keys) > 0 {
+				last = keys[len(keys)-1]
+			}
+			return nil
+		})
+		if err != nil {
+			return count, err
+		}
+		count += batchCount
+	}
+	return count, nil
+}
+
+// encrypt seals the provided plaintext with the current key. The result is laid out as version byte,
+// key id length, key id, nonce and ciphertext. The header and the context are authenticated as
+// additional data.
+func (ec *EncryptionCodec) encrypt(plaintext []byte) ([]byte, error) {
+	id, key, err := ec.keys.CurrentKey()
+	if err != nil {
+		return nil, fmt.Errorf("getting current key failed: %v", err)
+	}
+	if len(id) > 255 {
+		return nil, fmt.Errorf("key id %q is too long", id)
+	}
+
+	aead, err := newAEAD(key)
+	if err != nil {
+		return nil, err
+	}
+
+	header := append([]byte{encryptionVersion, byte(len(id))}, id...)
+	nonce := make([]byte, aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, fmt.Errorf("generating nonce failed: %v", err)
+	}
+
+	data := append(append([]byte{}, header...), nonce...)
+	return aead.Seal(data, nonce, plaintext, ec.additionalData(header)), nil
+}
+
+// decrypt opens the provided data and returns the id of the key that has been used together with the
+// plaintext.
+func (ec *EncryptionCodec) decrypt(data []byte) (string, []byte, error) {
+	if len(data) < 2 || data[0] != encryptionVersion {
+		return "", nil, errNotEncrypted
+	}
+	length := int(data[1])
+	if len(data) < 2+length {
+		return "", nil, errors.New("encryption header is too short")
+	}
+	header, id := data[:2+length], string(data[2:2+length])
+
+	key, err := ec.keys.Key(id)
+	if err != nil {
+		return "", nil, fmt.Errorf("getting key %q failed: %v", id, err)
+	}
+	aead, err := newAEAD(key)
+	if err != nil {
+		return "", nil, err
+	}
+
+	rest := data[len(header):]
+	if len(rest) < aead.NonceSize() {
+		return "", nil, errors.New("encrypted value is too short")
+	}
+	plaintext, err := aead.Open(nil, rest[:aead.NonceSize()], rest[aead.NonceSize():], ec.additionalData(header))
+	if err != nil {
+		return "", nil, fmt.Errorf("decrypting failed: %v", err)
+	}
+	return id, plaintext, nil
+}
+
+// additionalData returns the provided header followed by the context.
+func (ec *EncryptionCodec) additionalData(header []byte) []byte {
+	return append(append([]byte{}, header...), ec.context...)
+}
+
+func newAEAD(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, fmt.Errorf("creating cipher failed: %v", err)
+	}
+	return cipher.NewGCM(block)
+}
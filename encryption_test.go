@@ -0,0 +1,159 @@
+package boltx_test
+
+import (
+	"bytes"
+	"fmt"
+	"testing"
+
+	"github.com/boltdb/bolt"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/simia-tech/boltx"
+)
+
+var testKeys = map[string][]byte{
+	"one": bytes.Repeat([]byte{1}, 32),
+	"two": bytes.Repeat([]byte{2}, 16),
+}
+
+func TestEncryptionCodec(t *testing.T) {
+	codec := boltx.NewEncryptionCodec(boltx.RawCodec, boltx.NewStaticKeyProvider("one", testKeys))
+
+	data, err := codec.Marshal([]byte("secret"))
+	require.NoError(t, err)
+	assert.False(t, bytes.Contains(data, []byte("secret")))
+
+	result := []byte(nil)
+	require.NoError(t, codec.Unmarshal(data, &result))
+	assert.Equal(t, "secret", string(result))
+
+	tampered := append([]byte{}, data...)
+	tampered[len(tampered)-1] ^= 0xff
+	assert.Error(t, codec.Unmarshal(tampered, &result))
+	assert.Error(t, codec.Unmarshal([]byte("plain"), &result))
+
+	unknown := boltx.NewEncryptionCodec(boltx.RawCodec, boltx.NewStaticKeyProvider("three", testKeys))
+	_, err = unknown.Marshal([]byte("secret"))
+	assert.Error(t, err)
+}
+
+func TestEncryptionCodecWithModels(t *testing.T) {
+	db, tearDown := setUpTestDB(t)
+	defer tearDown()
+
+	codec := boltx.NewEncryptionCodec(nil, boltx.NewStaticKeyProvider("one", testKeys))
+
+	require.NoError(t, boltx.PutValueInBucket(db, []byte("test"), []byte("key"), &model{field: "secret"}, codec))
+	value := &model{}
+	found, err := boltx.GetValueFromBucket(db, []byte("test"), []byte("key"), value, codec)
+	require.NoError(t, err)
+	assert.True(t, found)
+	assert.Equal(t, "secret", value.field)
+
+	queue := boltx.NewQueue(db, []byte("queue"))
+	queue.SetCodec(codec)
+	require.NoError(t, queue.EnqueueValue(&model{field: "secret"}))
+	require.NoError(t, queue.DequeueValue(value))
+	assert.Equal(t, "secret", value.field)
+}
+
+func TestEncryptionCodecReencrypt(t *testing.T) {
+	db, tearDown := setUpTestDB(t)
+	defer tearDown()
+
+	old := boltx.NewEncryptionCodec(nil, boltx.NewStaticKeyProvider("one", testKeys))
+	inTestBucket(t, db, func(bucket *bolt.Bucket) {
+		for index := 0; index < 5; index++ {
+			key := []byte(fmt.Sprintf("key-%d", index))
+			require.NoError(t, boltx.PutValue(bucket, key, &model{field: string(key)}, old))
+		}
+	})
+
+	current := boltx.NewEncryptionCodec(nil, boltx.NewStaticKeyProvider("two", testKeys))
+	inTestBucket(t, db, func(bucket *bolt.Bucket) {
+		require.NoError(t, boltx.PutValue(bucket, []byte("key-5"), &model{field: "key-5"}, current))
+	})
+
+	count, err := current.Reencrypt(db, []byte("test"), 2)
+	require.NoError(t, err)
+	assert.Equal(t, 5, count)
+
+	count, err = current.Reencrypt(db, []byte("test"), 0)
+	require.NoError(t, err)
+	assert.Equal(t, 0, count)
+
+	only := boltx.NewEncryptionCodec(nil, boltx.NewStaticKeyProvider("two", map[string][]byte{"two": testKeys["two"]}))
+	inTestBucket(t, db, func(bucket *bolt.Bucket) {
+		items := 0
+		_, _, err := boltx.ForEachWithCodec(bucket, &model{}, only, func(key []byte, value interface{}) (boltx.Action, error) {
+			assert.Equal(t, string(key), value.(*model).field)
+			items++
+			return boltx.ActionContinue, nil
+		})
+		require.NoError(t, err)
+		assert.Equal(t, 6, items)
+	})
+
+	count, err = current.Reencrypt(db, []byte("missing"), 0)
+	require.NoError(t, err)
+	assert.Equal(t, 0, count)
+}
+
+func TestEncryptionCodecReencryptSkipsNestedBuckets(t *testing.T) {
+	db, tearDown := setUpTestDB(t)
+	defer tearDown()
+
+	old := boltx.NewEncryptionCodec(nil, boltx.NewStaticKeyProvider("one", testKeys))
+	inTestBucket(t, db, func(bucket *bolt.Bucket) {
+		require.NoError(t, boltx.PutValue(bucket, []byte("a"), &model{field: "a"}, old))
+		_, err := bucket.CreateBucket([]byte("b"))
+		require.NoError(t, err)
+		require.NoError(t, boltx.PutValue(bucket, []byte("c"), &model{field: "c"}, old))
+	})
+
+	current := boltx.NewEncryptionCodec(nil, boltx.NewStaticKeyProvider("two", testKeys))
+	count, err := current.Reencrypt(db, []byte("test"), 1)
+	require.NoError(t, err)
+	assert.Equal(t, 2, count)
+}
+
+func TestEncryptionCodecReencryptQueueWithEnvelope(t *testing.T) {
+	db, tearDown := setUpTestDB(t)
+	defer tearDown()
+
+	queue := boltx.NewQueue(db, []byte("test"))
+	queue.SetEnvelope(true)
+	queue.SetCodec(boltx.NewEncryptionCodec(nil, boltx.NewStaticKeyProvider("one", testKeys)))
+	require.NoError(t, queue.EnqueueValue(&model{field: "one"}))
+	require.NoError(t, queue.EnqueueValue(&model{field: "two"}))
+	inTestBucket(t, db, func(bucket *bolt.Bucket) {
+		require.NoError(t, bucket.Put([]byte{0xff}, []byte("plain")))
+	})
+
+	current := boltx.NewEncryptionCodec(nil, boltx.NewStaticKeyProvider("two", testKeys))
+	count, err := current.Reencrypt(db, []byte("test"), 0)
+	require.NoError(t, err)
+	assert.Equal(t, 2, count)
+
+	queue.SetCodec(boltx.NewEncryptionCodec(nil, boltx.NewStaticKeyProvider("two", map[string][]byte{"two": testKeys["two"]})))
+	for _, expected := range []string{"one", "two"} {
+		value := &model{}
+		require.NoError(t, queue.DequeueValue(value))
+		assert.Equal(t, expected, value.field)
+	}
+}
+
+func TestEncryptionCodecWithContext(t *testing.T) {
+	codec := boltx.NewEncryptionCodec(boltx.RawCodec, boltx.NewStaticKeyProvider("one", testKeys))
+
+	data, err := codec.WithContext([]byte("users")).Marshal([]byte("test"))
+	require.NoError(t, err)
+
+	result := []byte(nil)
+	require.NoError(t, codec.WithContext([]byte("users")).Unmarshal(data, &result))
+	assert.Equal(t, "test", string(result))
+
+	assert.Error(t, codec.WithContext([]byte("groups")).Unmarshal(data, &result))
+	assert.Error(t, codec.Unmarshal(data, &result))
+}
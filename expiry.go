@@ -0,0 +1,266 @@
+package boltx
+
+import (
+	"bytes"
+	"context"
+	"encoding"
+	"encoding/binary"
+	"time"
+
+	"github.com/boltdb/bolt"
+)
+
+// expireFunc defines a function that gets called with every expired value that has been removed from
+// a bucket.
+type expireFunc func(tx *bolt.Tx, key, value []byte) error
+
+// dropExpired is the expire function of buckets that simply drop their expired values.
+func dropExpired(tx *bolt.Tx, key, value []byte) error {
+	return nil
+}
+
+// Sweeper defines a queue or deque that can remove its expired elements.
+type Sweeper interface {
+	SweepExpired() (int, error)
+}
+
+// Sweep removes the expired elements of all the provided sweepers in the provided interval. The call
+// blocks until the provided context is done or a sweep fails. In the latter case, the error is returned.
+func Sweep(ctx context.Context, interval time.Duration, sweepers ...Sweeper) error {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		for _, sweeper := range sweepers {
+			if _, err := sweeper.SweepExpired(); err != nil {
+				return err
+			}
+		}
+
+		select {
+		case <-ticker.C:
+		case <-ctx.Done():
+			return nil
+		}
+	}
+}
+
+// EnqueueModelWithTTL puts the provided model to the back of the queue. Once the provided ttl has passed,
+// the element expires and is skipped by all dequeue calls. If the queue is full, the call blocks until
+// an element is dequeued. If the queue has no envelope, ErrNoEnvelope is returned.
+func (q *Queue) EnqueueModelWithTTL(model encoding.BinaryMarshaler, ttl time.Duration) error {
+	if !q.envelope {
+		return ErrNoEnvelope
+	}
+	message, err := newExpiringMessage(model, ttl)
+	if err != nil {
+		return err
+	}
+	return q.EnqueueModel(message)
+}
+
+// SetExpiredToDeadLetters sets whether expired elements are moved to the dead-letter queue instead of
+// being dropped. It should be called before the queue is used.
+func (q *Queue) SetExpiredToDeadLetters(enabled bool) {
+	q.expiredToDeadLetters = enabled
+}
+
+// SweepExpired removes all expired elements from the queue including the scheduled ones and returns
+// their number. Elements of a queue without an envelope never expire.
+func (q *Queue) SweepExpired() (int, error) {
+	if !q.envelope {
+		return 0, nil
+	}
+
+	count := 0
+	err := q.session.Update(func(tx *bolt.Tx) error {
+		now := time.Now()
+
+		swept, err := sweepBucket(tx, q.name, now, q.expire)
+		if err != nil {
+			return err
+		}
+		count += swept
+
+		swept, err = sweepBucket(tx, scheduledName(q.name), now, func(tx *bolt.Tx, key, value []byte) error {
+			return q.expireWithAttempts(tx, scheduledName(q.name), key, value)
+		})
+		count += swept
+		if count > 0 {
+			q.session.signalFreeOnCommit(tx, q.name)
+		}
+		return err
+	})
+	return count, err
+}
+
+// expiry returns the expire function of the queue or nil, if the queue's elements have no envelope and
+// therefore can't expire.
+func (q *Queue) expiry() expireFunc {
+	if !q.envelope {
+		return nil
+	}
+	return q.expire
+}
+
+// expire drops the attempts of the provided expired element and moves it to the dead-letter queue if
+// that's enabled.
+func (q *Queue) expire(tx *bolt.Tx, key, value []byte) error {
+	return q.expireWithAttempts(tx, q.name, key, value)
+}
+
+func (q *Queue) expireWithAttempts(tx *bolt.Tx, name, key, value []byte) error {
+	attempts, err := takeAttempts(tx, name, key)
+	if err != nil {
+		return err
+	}
+	if !q.expiredToDeadLetters {
+		return nil
+	}
+	return q.bury(tx, attempts, value, "expired")
+}
+
+// EnqueueModelFrontWithTTL puts the provided model to the front of the deque. Once the provided ttl has
+// passed, the element expires and is skipped by all dequeue calls. If the deque is full, the call blocks
+// until an element is dequeued. If the deque has no envelope, ErrNoEnvelope is returned.
+func (d *Deque) EnqueueModelFrontWithTTL(model encoding.BinaryMarshaler, ttl time.Duration) error {
+	if !d.envelope {
+		return ErrNoEnvelope
+	}
+	message, err := newExpiringMessage(model, ttl)
+	if err != nil {
+		return err
+	}
+	return d.EnqueueModelFront(message)
+}
+
+// EnqueueModelBackWithTTL puts the provided model to the back of the deque. Once the provided ttl has
+// passed, the element expires and is skipped by all dequeue calls. If the deque is full, the call blocks
+// until an element is dequeued. If the deque has no envelope, ErrNoEnvelope is returned.
+func (d *Deque) EnqueueModelBackWithTTL(model encoding.BinaryMarshaler, ttl time.Duration) error {
+	if !d.envelope {
+		return ErrNoEnvelope
+	}
+	message, err := newExpiringMessage(model, ttl)
+	if err != nil {
+		return err
+	}
+	return d.EnqueueModelBack(message)
+}
+
+// SweepExpired removes all expired elements from the deque and returns their number. Elements of a
+// deque without an envelope never expire.
+func (d *Deque) SweepExpired() (int, error) {
+	if !d.envelope {
+		return 0, nil
+	}
+
+	count := 0
+	err := d.session.Update(func(tx *bolt.Tx) (err error) {
+		if count, err = sweepBucket(tx, d.name, time.Now(), dropExpired); count > 0 {
+			d.session.signalFreeOnCommit(tx, d.name)
+		}
+		return
+	})
+	return count, err
+}
+
+// expiry returns the expire function of the deque or nil, if the deque's elements have no envelope and
+// therefore can't expire.
+func (d *Deque) expiry() expireFunc {
+	if !d.envelope {
+		return nil
+	}
+	return dropExpired
+}
+
+func newExpiringMessage(model encoding.BinaryMarshaler, ttl time.Duration) (*Message, error) {
+	message, err := NewMessage(model, nil)
+	if err != nil {
+		return nil, err
+	}
+	message.ExpiresAt = message.EnqueuedAt.Add(ttl)
+	return message, nil
+}
+
+// isExpired returns true if the provided message envelope expired before the provided time. It must only
+// be called with values that are known to be enveloped.
+func isExpired(value []byte, now time.Time) bool {
+	if !bytes.HasPrefix(value, envelopeMagic) || len(value) < len(envelopeMagic)+16 {
+		return false
+	}
+	offset := len(envelopeMagic) + 8
+	expiresAt := fromUnixNano(int64(binary.BigEndian.Uint64(value[offset : offset+8])))
+	return !expiresAt.IsZero() && !expiresAt.After(now)
+}
+
+// popLive pops values from the provided position until it finds one that hasn't expired. The expired
+// values are passed to the provided expire function and their number is returned as well. If the expire
+// function is nil, the values are not checked for expiry at all - that's the case for buckets without
+// envelopes.
+func popLive(tx *bolt.Tx, name []byte, position *Position, now time.Time, expire expireFunc) ([]byte, []byte, int, error) {
+	count := 0
+	for {
+		key, value := pop(tx, name, position)
+		if key == nil || expire == nil || !isExpired(value, now) {
+			return key, value, count, nil
+		}
+		count++
+		if err := expire(tx, key, value); err != nil {
+			return nil, nil, count, err
+		}
+	}
+}
+
+// peekLive returns the first key and value from the provided position that hasn't expired. Without the
+// envelope flag, the values are not checked for expiry.
+func peekLive(tx *bolt.Tx, name []byte, position *Position, now time.Time, envelope bool) ([]byte, []byte) {
+	bucket := tx.Bucket(name)
+	if bucket == nil {
+		return nil, nil
+	}
+	if !envelope {
+		return position.fn(bucket.Cursor())
+	}
+
+	cursor := bucket.Cursor()
+	next := cursor.Next
+	if position.delta > 0 {
+		next = cursor.Prev
+	}
+	for key, value := position.fn(cursor); key != nil; key, value = next() {
+		if !isExpired(value, now) {
+			return key, value
+		}
+	}
+	return nil, nil
+}
+
+// sweepBucket removes all expired values from the bucket with the provided name and passes them to the
+// provided expire function. The bucket has to hold enveloped values only. The number of removed values
+// is returned.
+func sweepBucket(tx *bolt.Tx, name []byte, now time.Time, expire expireFunc) (int, error) {
+	bucket := tx.Bucket(name)
+	if bucket == nil {
+		return 0, nil
+	}
+
+	expired := [][]byte{}
+	cursor := bucket.Cursor()
+	for key, value := cursor.First(); key != nil; key, value = cursor.Next() {
+		if isExpired(value, now) {
+			expired = append(expired, append([]byte{}, key...))
+		}
+	}
+
+	for _, key := range expired {
+		value := append([]byte{}, bucket.Get(key)...)
+		if err := bucket.Delete(key); err != nil {
+			return 0, err
+		}
+		if err := expire(tx, key, value); err != nil {
+			return 0, err
+		}
+	}
+	return len(expired), nil
+}
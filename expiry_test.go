@@ -0,0 +1,172 @@
+package boltx_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/simia-tech/boltx"
+)
+
+func TestQueueExpiry(t *testing.T) {
+	db, tearDown := setUpTestDB(t)
+	defer tearDown()
+
+	queue := boltx.NewQueue(db, []byte("test"))
+	queue.SetEnvelope(true)
+	require.NoError(t, queue.EnqueueModelWithTTL(&model{field: "expired"}, 10*time.Millisecond))
+	require.NoError(t, queue.EnqueueModelWithTTL(&model{field: "live"}, time.Minute))
+
+	time.Sleep(20 * time.Millisecond)
+
+	value := &model{}
+	require.NoError(t, queue.PeekModel(value))
+	assert.Equal(t, &model{field: "live"}, value)
+
+	require.NoError(t, queue.DequeueModel(value))
+	assert.Equal(t, &model{field: "live"}, value)
+
+	assert.Equal(t, boltx.ErrEmpty, queue.TryDequeueModel(value))
+	assert.Equal(t, 0, queue.Size())
+
+	deadLetters, err := queue.DeadLetters()
+	require.NoError(t, err)
+	assert.Empty(t, deadLetters)
+}
+
+func TestQueueExpiryToDeadLetters(t *testing.T) {
+	db, tearDown := setUpTestDB(t)
+	defer tearDown()
+
+	queue := boltx.NewQueue(db, []byte("test"))
+	queue.SetEnvelope(true)
+	queue.SetExpiredToDeadLetters(true)
+	require.NoError(t, queue.EnqueueModelWithTTL(&model{field: "test"}, 10*time.Millisecond))
+
+	time.Sleep(20 * time.Millisecond)
+
+	assert.Equal(t, boltx.ErrEmpty, queue.TryDequeueModel(&model{}))
+
+	deadLetters, err := queue.DeadLetters()
+	require.NoError(t, err)
+	require.Len(t, deadLetters, 1)
+	assert.Equal(t, "expired", deadLetters[0].Reason)
+
+	message := &boltx.Message{}
+	require.NoError(t, message.UnmarshalBinary(deadLetters[0].Value))
+	assert.Equal(t, "test", string(message.Payload))
+}
+
+func TestQueueSweepExpired(t *testing.T) {
+	db, tearDown := setUpTestDB(t)
+	defer tearDown()
+
+	queue := boltx.NewQueue(db, []byte("test"))
+	queue.SetEnvelope(true)
+	require.NoError(t, queue.EnqueueModelWithTTL(&model{field: "one"}, 10*time.Millisecond))
+	require.NoError(t, queue.EnqueueModel(&model{field: "two"}))
+	require.NoError(t, queue.EnqueueModelWithTTL(&model{field: "three"}, 10*time.Millisecond))
+
+	time.Sleep(20 * time.Millisecond)
+	assert.Equal(t, 3, queue.Size())
+
+	count, err := queue.SweepExpired()
+	require.NoError(t, err)
+	assert.Equal(t, 2, count)
+	assert.Equal(t, 1, queue.Size())
+}
+
+func TestDequeExpiry(t *testing.T) {
+	db, tearDown := setUpTestDB(t)
+	defer tearDown()
+
+	deque := boltx.NewDeque(db, []byte("test"))
+	deque.SetEnvelope(true)
+	require.NoError(t, deque.EnqueueModelBack(&model{field: "live"}))
+	require.NoError(t, deque.EnqueueModelBackWithTTL(&model{field: "back"}, 10*time.Millisecond))
+	require.NoError(t, deque.EnqueueModelFrontWithTTL(&model{field: "front"}, 10*time.Millisecond))
+
+	time.Sleep(20 * time.Millisecond)
+
+	value := &model{}
+	require.NoError(t, deque.PeekModelBack(value))
+	assert.Equal(t, &model{field: "live"}, value)
+	require.NoError(t, deque.DequeueModelBack(value))
+	assert.Equal(t, &model{field: "live"}, value)
+	assert.Equal(t, 1, deque.Size())
+
+	count, err := deque.SweepExpired()
+	require.NoError(t, err)
+	assert.Equal(t, 1, count)
+	assert.Equal(t, 0, deque.Size())
+}
+
+func TestSweep(t *testing.T) {
+	db, tearDown := setUpTestDB(t)
+	defer tearDown()
+
+	queue := boltx.NewQueue(db, []byte("queue"))
+	queue.SetEnvelope(true)
+	deque := boltx.NewDeque(db, []byte("deque"))
+	deque.SetEnvelope(true)
+	require.NoError(t, queue.EnqueueModelWithTTL(&model{field: "test"}, 10*time.Millisecond))
+	require.NoError(t, deque.EnqueueModelBackWithTTL(&model{field: "test"}, 10*time.Millisecond))
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	require.NoError(t, boltx.Sweep(ctx, 5*time.Millisecond, queue, deque))
+
+	assert.Equal(t, 0, queue.Size())
+	assert.Equal(t, 0, deque.Size())
+}
+
+func TestExpiryWithoutEnvelope(t *testing.T) {
+	db, tearDown := setUpTestDB(t)
+	defer tearDown()
+
+	queue := boltx.NewQueue(db, []byte("queue"))
+	assert.Equal(t, boltx.ErrNoEnvelope, queue.EnqueueModelWithTTL(&model{field: "test"}, time.Minute))
+
+	deque := boltx.NewDeque(db, []byte("deque"))
+	assert.Equal(t, boltx.ErrNoEnvelope, deque.EnqueueModelBackWithTTL(&model{field: "test"}, time.Minute))
+	assert.Equal(t, boltx.ErrNoEnvelope, deque.EnqueueModelFrontWithTTL(&model{field: "test"}, time.Minute))
+}
+
+func TestExpiryIgnoresValuesWithoutEnvelope(t *testing.T) {
+	db, tearDown := setUpTestDB(t)
+	defer tearDown()
+
+	// an envelope prefix followed by an expiry in the past
+	payload := []byte{0xff, 'b', 'x', 0x01, 0, 0, 0, 0, 0, 0, 0, 1, 0, 0, 0, 0, 0, 0, 0, 1, 'x'}
+
+	queue := boltx.NewQueue(db, []byte("queue"))
+	queue.SetCodec(boltx.RawCodec)
+	require.NoError(t, queue.EnqueueValue(payload))
+	require.NoError(t, queue.EnqueueValue(payload))
+
+	deque := boltx.NewDeque(db, []byte("deque"))
+	deque.SetCodec(boltx.RawCodec)
+	require.NoError(t, deque.EnqueueValueBack(payload))
+
+	require.NoError(t, boltx.Sweep(canceledContext(), time.Millisecond, queue, deque))
+	assert.Equal(t, 2, queue.Size())
+	assert.Equal(t, 1, deque.Size())
+
+	value := []byte(nil)
+	require.NoError(t, queue.DequeueValue(&value))
+	assert.Equal(t, payload, value)
+	require.NoError(t, deque.DequeueValueFront(&value))
+	assert.Equal(t, payload, value)
+
+	_, err := boltx.Select(context.Background(), &model{}, queue)
+	require.NoError(t, err)
+}
+
+func canceledContext() context.Context {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	return ctx
+}
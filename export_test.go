@@ -0,0 +1,12 @@
+package boltx
+
+import "github.com/boltdb/bolt"
+
+// HasHub returns true if a notification hub is held for the provided db.
+func HasHub(db *bolt.DB) bool {
+	hubs.mutex.Lock()
+	defer hubs.mutex.Unlock()
+
+	_, ok := hubs.hubs[db]
+	return ok
+}
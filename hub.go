@@ -0,0 +1,129 @@
+package boltx
+
+import (
+	"sync"
+
+	"github.com/boltdb/bolt"
+)
+
+// hubs holds the notification hub of every db an open session has been created for.
+var hubs = struct {
+	mutex sync.Mutex
+	hubs  map[*bolt.DB]*hub
+}{hubs: map[*bolt.DB]*hub{}}
+
+// hub hands out one notifier per bucket, so all sessions on the same db wake each other.
+type hub struct {
+	mutex     sync.Mutex
+	notifiers map[string]*notifier
+	sessions  int
+}
+
+// acquireHub returns the notification hub of the provided db and counts the calling session in.
+func acquireHub(db *bolt.DB) *hub {
+	hubs.mutex.Lock()
+	defer hubs.mutex.Unlock()
+
+	h, ok := hubs.hubs[db]
+	if !ok {
+		h = &hub{notifiers: map[string]*notifier{}}
+		hubs.hubs[db] = h
+	}
+	h.sessions++
+	return h
+}
+
+// releaseHub counts the calling session out of the notification hub of the provided db. The hub is
+// dropped with the last session.
+func releaseHub(db *bolt.DB) {
+	hubs.mutex.Lock()
+	defer hubs.mutex.Unlock()
+
+	h, ok := hubs.hubs[db]
+	if !ok {
+		return
+	}
+	if h.sessions--; h.sessions < 1 {
+		delete(hubs.hubs, db)
+	}
+}
+
+// notifier returns the notifier of the bucket with the provided name.
+func (h *hub) notifier(name []byte) *notifier {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+
+	n, ok := h.notifiers[string(name)]
+	if !ok {
+		n = &notifier{
+			updated: make(chan struct{}),
+			freed:   make(chan struct{}),
+		}
+		h.notifiers[string(name)] = n
+	}
+	return n
+}
+
+// signalsFunc defines a function that returns the channels that are closed with the next signal.
+type signalsFunc func() []<-chan struct{}
+
+// nextUpdates returns a function that returns the channels that are closed with the next update of
+// any of the provided notifiers.
+func nextUpdates(notifiers ...*notifier) signalsFunc {
+	return func() []<-chan struct{} {
+		signals := make([]<-chan struct{}, len(notifiers))
+		for index, n := range notifiers {
+			signals[index] = n.nextUpdate()
+		}
+		return signals
+	}
+}
+
+// nextFrees returns a function that returns the channels that are closed with the next removal from
+// any of the provided notifiers.
+func nextFrees(notifiers ...*notifier) signalsFunc {
+	return func() []<-chan struct{} {
+		signals := make([]<-chan struct{}, len(notifiers))
+		for index, n := range notifiers {
+			signals[index] = n.nextFree()
+		}
+		return signals
+	}
+}
+
+// notifier signals updates and removals of a single bucket.
+type notifier struct {
+	mutex   sync.Mutex
+	updated chan struct{}
+	freed   chan struct{}
+}
+
+// nextUpdate returns a channel that is closed with the next signaled update.
+func (n *notifier) nextUpdate() <-chan struct{} {
+	n.mutex.Lock()
+	defer n.mutex.Unlock()
+	return n.updated
+}
+
+// nextFree returns a channel that is closed with the next signaled removal.
+func (n *notifier) nextFree() <-chan struct{} {
+	n.mutex.Lock()
+	defer n.mutex.Unlock()
+	return n.freed
+}
+
+// signalUpdate wakes up all callers that are waiting for an update.
+func (n *notifier) signalUpdate() {
+	n.mutex.Lock()
+	defer n.mutex.Unlock()
+	close(n.updated)
+	n.updated = make(chan struct{})
+}
+
+// signalFree wakes up all callers that are waiting for a removal.
+func (n *notifier) signalFree() {
+	n.mutex.Lock()
+	defer n.mutex.Unlock()
+	close(n.freed)
+	n.freed = make(chan struct{})
+}
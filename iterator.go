@@ -2,7 +2,6 @@ package boltx
 
 import (
 	"encoding"
-	"fmt"
 	"reflect"
 
 	"github.com/boltdb/bolt"
@@ -25,11 +24,24 @@ const (
 // Action indicates how an element should be handled after the iterator went over it.
 type Action int
 
-// ForEach iterates over all elements in the bucket.
+// ForEach iterates over all elements in the bucket. The elements are unmarshaled into new instances of
+// the prototype's type.
 func ForEach(
 	bucket *bolt.Bucket,
 	prototype encoding.BinaryUnmarshaler,
 	fn func([]byte, interface{}) (Action, error),
+) ([]byte, interface{}, error) {
+	return ForEachWithCodec(bucket, prototype, BinaryCodec, fn)
+}
+
+// ForEachWithCodec behaves like ForEach, but unmarshals the elements with the provided codec into new
+// instances of the prototype's type. Updated elements are marshaled with the codec as well. A checksum
+// mismatch is returned without the bucket name.
+func ForEachWithCodec(
+	bucket *bolt.Bucket,
+	prototype interface{},
+	codec Codec,
+	fn func([]byte, interface{}) (Action, error),
 ) ([]byte, interface{}, error) {
 	t := reflect.ValueOf(prototype).Type()
 	if t.Kind() == reflect.Ptr {
@@ -38,10 +50,10 @@ func ForEach(
 
 	cursor := bucket.Cursor()
 	for key, value := cursor.First(); key != nil; key, value = cursor.Next() {
-		model := reflect.New(t).Interface().(encoding.BinaryUnmarshaler)
+		model := reflect.New(t).Interface()
 
-		if err := model.UnmarshalBinary(value); err != nil {
-			return nil, nil, fmt.Errorf("unmarshaling failed: %v", err)
+		if err := unmarshalValue(codec, nil, key, value, model); err != nil {
+			return nil, nil, err
 		}
 
 		action, err := fn(key, model)
@@ -54,11 +66,7 @@ func ForEach(
 				return nil, nil, err
 			}
 		} else if ActionUpdate&action != 0 {
-			bm, ok := model.(encoding.BinaryMarshaler)
-			if !ok {
-				return nil, nil, fmt.Errorf("prototype %T has to implement encoding.BinaryMarshaler in order to update", prototype)
-			}
-			if err := PutModel(bucket, key, bm); err != nil {
+			if err := PutValue(bucket, key, model, codec); err != nil {
 				return nil, nil, err
 			}
 		}
@@ -0,0 +1,167 @@
+package boltx
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math"
+	"math/big"
+	"sort"
+
+	"github.com/boltdb/bolt"
+)
+
+var (
+	// ErrKeyExhausted is returned if no further key can be generated, because the key space of the key
+	// scheme is used up in the requested direction.
+	ErrKeyExhausted = errors.New("key space exhausted")
+
+	// Uint64QueueKeyScheme defines a key scheme with fixed-width, big-endian uint64 keys that starts
+	// at zero. It's suitable for sequences that only grow to the back. A Queue using it can't put
+	// redelivered elements in front of the key zero and puts them to the back instead. Use
+	// Uint64DequeKeyScheme to keep their position.
+	Uint64QueueKeyScheme = NewUint64KeyScheme(0)
+
+	// Uint64DequeKeyScheme defines a key scheme with fixed-width, big-endian uint64 keys that starts
+	// in the centre of the key space. It's suitable for deques that grow in both directions.
+	Uint64DequeKeyScheme = NewUint64KeyScheme(math.MaxUint64 / 2)
+)
+
+// KeyScheme defines how the keys of the values in a queue or deque are generated. The generated
+// keys have to sort in bolt's byte order like the values should be ordered.
+type KeyScheme interface {
+	// DefaultKey returns the key for the first value in an empty bucket.
+	DefaultKey() []byte
+
+	// Next returns the key that follows the provided key in the direction of the provided delta.
+	Next(key []byte, delta int64) ([]byte, error)
+}
+
+type uint64KeyScheme struct {
+	start uint64
+}
+
+// NewUint64KeyScheme returns a key scheme with fixed-width, big-endian uint64 keys that starts at
+// the provided value.
+func NewUint64KeyScheme(start uint64) KeyScheme {
+	return &uint64KeyScheme{start: start}
+}
+
+func (s *uint64KeyScheme) DefaultKey() []byte {
+	key := make([]byte, 8)
+	binary.BigEndian.PutUint64(key, s.start)
+	return key
+}
+
+func (s *uint64KeyScheme) Next(key []byte, delta int64) ([]byte, error) {
+	if len(key) != 8 {
+		return nil, fmt.Errorf("key %x is not a uint64 key - the bucket might need a migration", key)
+	}
+
+	value := binary.BigEndian.Uint64(key)
+	if (delta > 0 && value > math.MaxUint64-uint64(delta)) || (delta < 0 && value < uint64(-delta)) {
+		return nil, ErrKeyExhausted
+	}
+
+	next := make([]byte, 8)
+	binary.BigEndian.PutUint64(next, value+uint64(delta))
+	return next, nil
+}
+
+// defaultKeyScheme implements a key scheme with fixed-width, big-endian keys that have the width of
+// the default key.
+type defaultKeyScheme []byte
+
+func (s defaultKeyScheme) DefaultKey() []byte {
+	return s
+}
+
+func (s defaultKeyScheme) Next(key []byte, delta int64) ([]byte, error) {
+	if len(key) != len(s) {
+		return nil, fmt.Errorf("key %x doesn't have the width of the default key - the bucket might need a migration", key)
+	}
+	return addToKey(key, delta)
+}
+
+func addToKey(key []byte, value int64) ([]byte, error) {
+	sum := big.NewInt(0).Add(big.NewInt(0).SetBytes(key), big.NewInt(value))
+	if sum.Sign() < 0 || sum.BitLen() > len(key)*8 {
+		return nil, ErrKeyExhausted
+	}
+
+	sumBytes := sum.Bytes()
+	result := make([]byte, len(key))
+	copy(result[len(result)-len(sumBytes):], sumBytes)
+	return result, nil
+}
+
+// MigrateKeys rewrites the keys of all values in the bucket with the provided name to the provided
+// key scheme. The existing keys are interpreted as unsigned big-endian numbers of any width - like
+// they've been written by earlier versions of this package. The values keep their numeric order and
+// get the keys that the key scheme generates starting at its default key. The delivery attempts of
+// the values are moved to the new keys as well.
+func MigrateKeys(tx *bolt.Tx, name []byte, scheme KeyScheme) error {
+	bucket := tx.Bucket(name)
+	if bucket == nil {
+		return nil
+	}
+
+	type entry struct {
+		number   *big.Int
+		value    []byte
+		attempts []byte
+	}
+	attempts := tx.Bucket(attemptsName(name))
+	entries := []entry{}
+	cursor := bucket.Cursor()
+	for key, value := cursor.First(); key != nil; key, value = cursor.Next() {
+		entry := entry{
+			number: big.NewInt(0).SetBytes(key),
+			value:  append([]byte{}, value...),
+		}
+		if attempts != nil {
+			if value := attempts.Get(key); value != nil {
+				entry.attempts = append([]byte{}, value...)
+			}
+		}
+		entries = append(entries, entry)
+	}
+	sort.SliceStable(entries, func(i, j int) bool {
+		return entries[i].number.Cmp(entries[j].number) < 0
+	})
+
+	if err := tx.DeleteBucket(name); err != nil {
+		return err
+	}
+	bucket, err := tx.CreateBucket(name)
+	if err != nil {
+		return err
+	}
+	if attempts != nil {
+		if err := tx.DeleteBucket(attemptsName(name)); err != nil {
+			return err
+		}
+		if attempts, err = tx.CreateBucket(attemptsName(name)); err != nil {
+			return err
+		}
+	}
+
+	key := scheme.DefaultKey()
+	for index, entry := range entries {
+		if index > 0 {
+			if key, err = scheme.Next(key, 1); err != nil {
+				return err
+			}
+		}
+		if err := bucket.Put(key, entry.value); err != nil {
+			return err
+		}
+		if entry.attempts != nil {
+			if err := attempts.Put(key, entry.attempts); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,161 @@
+package boltx_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"testing"
+	"time"
+
+	"github.com/boltdb/bolt"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/simia-tech/boltx"
+)
+
+func TestUint64KeyScheme(t *testing.T) {
+	scheme := boltx.NewUint64KeyScheme(1)
+	assert.Equal(t, []byte{0, 0, 0, 0, 0, 0, 0, 1}, scheme.DefaultKey())
+
+	key, err := scheme.Next(scheme.DefaultKey(), 255)
+	require.NoError(t, err)
+	assert.Equal(t, []byte{0, 0, 0, 0, 0, 0, 1, 0}, key)
+
+	key, err = scheme.Next(scheme.DefaultKey(), -1)
+	require.NoError(t, err)
+	assert.Equal(t, []byte{0, 0, 0, 0, 0, 0, 0, 0}, key)
+
+	_, err = scheme.Next(key, -1)
+	assert.Equal(t, boltx.ErrKeyExhausted, err)
+
+	_, err = boltx.NewUint64KeyScheme(math.MaxUint64).Next(boltx.NewUint64KeyScheme(math.MaxUint64).DefaultKey(), 1)
+	assert.Equal(t, boltx.ErrKeyExhausted, err)
+
+	_, err = scheme.Next([]byte{0x01}, 1)
+	assert.Error(t, err)
+}
+
+func TestPushKeepsOrderAcrossByteBoundaries(t *testing.T) {
+	db, tearDown := setUpTestDB(t)
+	defer tearDown()
+
+	name := []byte("test")
+
+	require.NoError(t, db.Update(func(tx *bolt.Tx) error {
+		for index := 0; index < 300; index++ {
+			require.NoError(t, boltx.Push(tx, name, boltx.PositionBack, []byte(fmt.Sprintf("%d", index)), boltx.DefaultUint64QueueKey))
+		}
+		for index := 0; index < 300; index++ {
+			assert.Equal(t, fmt.Sprintf("%d", index), string(boltx.Pop(tx, name, boltx.PositionFront)))
+		}
+		return nil
+	}))
+}
+
+func TestPushWithExhaustedKeys(t *testing.T) {
+	db, tearDown := setUpTestDB(t)
+	defer tearDown()
+
+	name := []byte("test")
+
+	require.NoError(t, db.Update(func(tx *bolt.Tx) error {
+		require.NoError(t, boltx.Push(tx, name, boltx.PositionBack, []byte("one"), boltx.DefaultUint64QueueKey))
+		assert.Equal(t, boltx.ErrKeyExhausted, boltx.Push(tx, name, boltx.PositionFront, []byte("two"), boltx.DefaultUint64QueueKey))
+
+		require.NoError(t, boltx.Push(tx, []byte("short"), boltx.PositionBack, []byte("one"), []byte{0xff}))
+		assert.Equal(t, boltx.ErrKeyExhausted, boltx.Push(tx, []byte("short"), boltx.PositionBack, []byte("two"), []byte{0xff}))
+
+		assert.Error(t, boltx.Push(tx, []byte("short"), boltx.PositionBack, []byte("two"), boltx.DefaultUint64QueueKey))
+		return nil
+	}))
+}
+
+func TestPushWithKeyScheme(t *testing.T) {
+	db, tearDown := setUpTestDB(t)
+	defer tearDown()
+
+	name := []byte("test")
+
+	require.NoError(t, db.Update(func(tx *bolt.Tx) error {
+		require.NoError(t, boltx.PushWithKeyScheme(tx, name, boltx.PositionBack, []byte("two"), boltx.Uint64DequeKeyScheme))
+		require.NoError(t, boltx.PushWithKeyScheme(tx, name, boltx.PositionFront, []byte("one"), boltx.Uint64DequeKeyScheme))
+
+		key, value := tx.Bucket(name).Cursor().First()
+		assert.Len(t, key, 8)
+		assert.Equal(t, "one", string(value))
+		return nil
+	}))
+}
+
+func TestMigrateKeys(t *testing.T) {
+	db, tearDown := setUpTestDB(t)
+	defer tearDown()
+
+	name := []byte("test")
+
+	require.NoError(t, db.Update(func(tx *bolt.Tx) error {
+		bucket, err := tx.CreateBucket(name)
+		require.NoError(t, err)
+		require.NoError(t, bucket.Put([]byte{0, 0, 0, 0, 0, 0, 0, 0}, []byte("zero")))
+		require.NoError(t, bucket.Put([]byte{0x02}, []byte("two")))
+		require.NoError(t, bucket.Put([]byte{0x01, 0x00}, []byte("two-hundred-fifty-six")))
+		require.NoError(t, bucket.Put([]byte{0xff}, []byte("two-hundred-fifty-five")))
+
+		require.NoError(t, boltx.MigrateKeys(tx, name, boltx.Uint64QueueKeyScheme))
+		require.NoError(t, boltx.MigrateKeys(tx, []byte("missing"), boltx.Uint64QueueKeyScheme))
+		return nil
+	}))
+
+	queue := boltx.NewQueue(db, name)
+	queue.SetKeyScheme(boltx.Uint64QueueKeyScheme)
+	require.NoError(t, queue.EnqueueModel(&model{field: "last"}))
+
+	value := &model{}
+	for _, expected := range []string{"zero", "two", "two-hundred-fifty-five", "two-hundred-fifty-six", "last"} {
+		require.NoError(t, queue.DequeueModel(value))
+		assert.Equal(t, expected, value.field)
+	}
+}
+
+func TestMigrateKeysWithAttempts(t *testing.T) {
+	db, tearDown := setUpTestDB(t)
+	defer tearDown()
+
+	queue := boltx.NewQueue(db, []byte("test"))
+	queue.SetMaxAttempts(2)
+
+	require.NoError(t, db.Update(func(tx *bolt.Tx) error {
+		bucket, err := tx.CreateBucket([]byte("test"))
+		require.NoError(t, err)
+		require.NoError(t, bucket.Put([]byte{0x01}, []byte("one")))
+		require.NoError(t, bucket.Put([]byte{0x02}, []byte("two")))
+
+		attempts, err := tx.CreateBucket([]byte("test:attempts"))
+		require.NoError(t, err)
+		require.NoError(t, attempts.Put([]byte{0x02}, []byte{0, 0, 0, 1}))
+
+		require.NoError(t, boltx.MigrateKeys(tx, []byte("test"), boltx.Uint64DequeKeyScheme))
+		return nil
+	}))
+
+	value := &model{}
+	receipt, err := queue.ReceiveModel(context.Background(), value, time.Minute)
+	require.NoError(t, err)
+	assert.Equal(t, "one", value.field)
+	require.NoError(t, queue.Fail(receipt, errors.New("failure")))
+	assert.Equal(t, 2, queue.Size())
+
+	require.NoError(t, queue.DequeueModel(value))
+	receipt, err = queue.ReceiveModel(context.Background(), value, time.Minute)
+	require.NoError(t, err)
+	assert.Equal(t, "two", value.field)
+	require.NoError(t, queue.Fail(receipt, errors.New("failure")))
+	assert.Equal(t, 0, queue.Size())
+
+	deadLetters, err := queue.DeadLetters()
+	require.NoError(t, err)
+	require.Len(t, deadLetters, 1)
+	assert.Equal(t, 2, deadLetters[0].Attempts)
+}
@@ -0,0 +1,177 @@
+package boltx
+
+import (
+	"context"
+	"encoding"
+	"encoding/binary"
+	"errors"
+	"time"
+
+	"github.com/boltdb/bolt"
+)
+
+// ErrUnknownReceipt is returned if a receipt doesn't refer to an element in flight. That's the case if
+// the element has already been acknowledged or its lease has expired and it was delivered again.
+var ErrUnknownReceipt = errors.New("unknown receipt")
+
+// Receipt identifies an element that has been received from a queue and is in flight until it gets
+// acknowledged.
+type Receipt []byte
+
+// ReceiveModel gets the value from the front of the queue, unmarshals it into the provided model and
+// moves it to the in-flight elements. The element stays there until it's acknowledged with Ack or
+// returned with Nack. If neither happens before the provided lease expires, the element is put back to
+// the front of the queue - also if the process was restarted in between. If the queue is empty the call
+// blocks until an element is enqueued or the provided context is done. An element that can't be
+// unmarshaled is failed right away with the unmarshaling error, so it's moved to the dead-letter queue
+// once it reached the maximum number of attempts.
+func (q *Queue) ReceiveModel(ctx context.Context, model encoding.BinaryUnmarshaler, lease time.Duration) (Receipt, error) {
+	receipt, _, err := q.receive(ctx, lease, func(key, value []byte) error {
+		if err := unmarshalElement(model, value, q.envelope); err != nil {
+			return unmarshalError(err, q.name, key)
+		}
+		return nil
+	})
+	return receipt, err
+}
+
+// receive moves the value from the front of the queue to the in-flight elements and passes it together
+// with its key to the provided function within the same transaction. If the function fails, the value
+// is released like a failed one and the function's error is returned. The receipt and the number of
+// delivery attempts including the current one are returned.
+func (q *Queue) receive(ctx context.Context, lease time.Duration, fn func(key, value []byte) error) (Receipt, int, error) {
+	receipt, attempts, failure := Receipt(nil), 0, error(nil)
+	err := popOrWait(ctx, q.session, q.name, PositionFront, 1, true, q.ready, q.expiry(), func(tx *bolt.Tx, keys, values [][]byte) (err error) {
+		if attempts, err = takeAttempts(tx, q.name, keys[0]); err != nil {
+			return err
+		}
+		attempts++
+
+		if failure = fn(keys[0], values[0]); failure != nil {
+			if err := q.release(tx, attempts, append([]byte{}, values[0]...), failure.Error(), time.Time{}); err != nil {
+				return err
+			}
+			q.session.broadcastOnCommit(tx, q.name)
+			return nil
+		}
+
+		receipt, err = pushInFlight(tx, q.name, time.Now().Add(lease), attempts, values[0])
+		return err
+	})
+	if err != nil {
+		return nil, attempts, err
+	}
+	return receipt, attempts, failure
+}
+
+// Ack acknowledges the element with the provided receipt and removes it finally. If the receipt is
+// unknown, ErrUnknownReceipt is returned.
+func (q *Queue) Ack(receipt Receipt) error {
+	return q.session.Update(func(tx *bolt.Tx) error {
+		_, _, err := popInFlight(tx, q.name, receipt)
+		return err
+	})
+}
+
+// Nack returns the element with the provided receipt to the front of the queue, so it gets delivered
+// again. If the element reached the maximum number of attempts, it's moved to the dead letters instead.
+// If the receipt is unknown, ErrUnknownReceipt is returned.
+func (q *Queue) Nack(receipt Receipt) error {
+	return q.Fail(receipt, errors.New("negative acknowledged"))
+}
+
+// InFlightSize returns the number of received elements that are neither acknowledged nor returned.
+func (q *Queue) InFlightSize() int {
+	return BucketSize(q.db, inFlightName(q.name))
+}
+
+// requeueExpired releases all in-flight values with an expired lease. The deadline of the next lease is
+// returned, or the zero time if there is none. The number of released values is returned as well.
+func (q *Queue) requeueExpired(tx *bolt.Tx, now time.Time) (time.Time, int, error) {
+	bucket := tx.Bucket(inFlightName(q.name))
+	if bucket == nil {
+		return time.Time{}, 0, nil
+	}
+
+	count := 0
+	cursor := bucket.Cursor()
+	for key, value := cursor.First(); key != nil; key, value = cursor.First() {
+		deadline := time.Unix(0, int64(binary.BigEndian.Uint64(key[:8])))
+		if deadline.After(now) {
+			return deadline, count, nil
+		}
+
+		attempts, value := decodeInFlight(value)
+		if err := q.release(tx, attempts, value, "lease expired", time.Time{}); err != nil {
+			return time.Time{}, count, err
+		}
+		if err := cursor.Delete(); err != nil {
+			return time.Time{}, count, err
+		}
+		count++
+	}
+
+	return time.Time{}, count, nil
+}
+
+// inFlightName returns the name of the bucket that holds the in-flight values of the bucket with the
+// provided name.
+func inFlightName(name []byte) []byte {
+	return append(append([]byte{}, name...), ":inflight"...)
+}
+
+// pushInFlight inserts the provided value into the in-flight bucket of the bucket with the provided name.
+// The key is composed of the lease deadline and the bucket's sequence and is returned as the receipt.
+func pushInFlight(tx *bolt.Tx, name []byte, deadline time.Time, attempts int, value []byte) (Receipt, error) {
+	bucket, err := tx.CreateBucketIfNotExists(inFlightName(name))
+	if err != nil {
+		return nil, err
+	}
+
+	sequence, err := bucket.NextSequence()
+	if err != nil {
+		return nil, err
+	}
+
+	key := make([]byte, 16)
+	binary.BigEndian.PutUint64(key[:8], uint64(deadline.UnixNano()))
+	binary.BigEndian.PutUint64(key[8:], sequence)
+
+	if err := bucket.Put(key, encodeInFlight(attempts, value)); err != nil {
+		return nil, err
+	}
+
+	return Receipt(key), nil
+}
+
+// popInFlight removes the in-flight value with the provided receipt and returns it together with the
+// number of delivery attempts.
+func popInFlight(tx *bolt.Tx, name []byte, receipt Receipt) (int, []byte, error) {
+	bucket := tx.Bucket(inFlightName(name))
+	if bucket == nil {
+		return 0, nil, ErrUnknownReceipt
+	}
+
+	data := bucket.Get(receipt)
+	if data == nil {
+		return 0, nil, ErrUnknownReceipt
+	}
+
+	if err := bucket.Delete(receipt); err != nil {
+		return 0, nil, err
+	}
+
+	attempts, value := decodeInFlight(data)
+	return attempts, value, nil
+}
+
+func encodeInFlight(attempts int, value []byte) []byte {
+	data := make([]byte, 4+len(value))
+	binary.BigEndian.PutUint32(data[:4], uint32(attempts))
+	copy(data[4:], value)
+	return data
+}
+
+func decodeInFlight(data []byte) (int, []byte) {
+	return int(binary.BigEndian.Uint32(data[:4])), data[4:]
+}
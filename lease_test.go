@@ -0,0 +1,79 @@
+package boltx_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/simia-tech/boltx"
+)
+
+func TestQueueReceiveAndAck(t *testing.T) {
+	db, tearDown := setUpTestDB(t)
+	defer tearDown()
+
+	queue := boltx.NewQueue(db, []byte("test"))
+	require.NoError(t, queue.EnqueueModel(&model{field: "test"}))
+
+	value := &model{}
+	receipt, err := queue.ReceiveModel(context.Background(), value, time.Minute)
+	require.NoError(t, err)
+	assert.Equal(t, "test", value.field)
+	assert.Equal(t, 0, queue.Size())
+	assert.Equal(t, 1, queue.InFlightSize())
+
+	require.NoError(t, queue.Ack(receipt))
+	assert.Equal(t, 0, queue.InFlightSize())
+
+	assert.Equal(t, boltx.ErrUnknownReceipt, queue.Ack(receipt))
+	assert.Equal(t, boltx.ErrUnknownReceipt, queue.Nack(receipt))
+}
+
+func TestQueueReceiveAndNack(t *testing.T) {
+	db, tearDown := setUpTestDB(t)
+	defer tearDown()
+
+	queue := boltx.NewQueue(db, []byte("test"))
+	require.NoError(t, queue.EnqueueModel(&model{field: "one"}))
+	require.NoError(t, queue.EnqueueModel(&model{field: "two"}))
+
+	value := &model{}
+	receipt, err := queue.ReceiveModel(context.Background(), value, time.Minute)
+	require.NoError(t, err)
+	assert.Equal(t, "one", value.field)
+
+	require.NoError(t, queue.Nack(receipt))
+	assert.Equal(t, 2, queue.Size())
+	assert.Equal(t, 0, queue.InFlightSize())
+
+	require.NoError(t, queue.DequeueModel(value))
+	assert.Equal(t, "one", value.field)
+}
+
+func TestQueueReceiveWithExpiredLease(t *testing.T) {
+	db, tearDown := setUpTestDB(t)
+	defer tearDown()
+
+	queue := boltx.NewQueue(db, []byte("test"))
+	require.NoError(t, queue.EnqueueModel(&model{field: "test"}))
+
+	value := &model{}
+	receipt, err := queue.ReceiveModel(context.Background(), value, 20*time.Millisecond)
+	require.NoError(t, err)
+
+	restartedQueue := boltx.NewQueue(db, []byte("test"))
+	_, err = restartedQueue.ReceiveModel(context.Background(), value, time.Minute)
+	require.NoError(t, err)
+	assert.Equal(t, "test", value.field)
+
+	assert.Equal(t, boltx.ErrUnknownReceipt, queue.Ack(receipt))
+	assert.Equal(t, 1, queue.InFlightSize())
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	_, err = queue.ReceiveModel(ctx, value, time.Minute)
+	assert.Equal(t, context.DeadlineExceeded, err)
+}
@@ -0,0 +1,333 @@
+package boltx
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/boltdb/bolt"
+)
+
+// envelopeMagic prefixes all values that are wrapped in a message envelope.
+var envelopeMagic = []byte{0xff, 'b', 'x', 0x01}
+
+// ErrNoEnvelope is returned if a message or an expiring element should be put into a queue or deque
+// without an envelope.
+var ErrNoEnvelope = errors.New("queue has no envelope")
+
+// Message defines an element of a queue together with its metadata. A queue or deque only stores
+// messages if the envelope has been enabled via SetEnvelope.
+type Message struct {
+	// ID identifies the message. It's generated by EnqueueMessage.
+	ID string
+	// EnqueuedAt holds the time when the message has been enqueued.
+	EnqueuedAt time.Time
+	// ExpiresAt holds the time when the message expires. Expired messages are skipped by all dequeue
+	// calls. The zero time means that the message never expires.
+	ExpiresAt time.Time
+	// Headers holds the producer-supplied headers.
+	Headers map[string]string
+	// Attempts holds the number of delivery attempts including the current one. It's not part of the
+	// marshaled data, but set on dequeue.
+	Attempts int
+	// Payload holds the marshaled model.
+	Payload []byte
+}
+
+// NewMessage returns a new message with a random id that wraps the provided model and headers.
+func NewMessage(model encoding.BinaryMarshaler, headers map[string]string) (*Message, error) {
+	payload, err := model.MarshalBinary()
+	if err != nil {
+		return nil, fmt.Errorf("marshaling failed: %v", err)
+	}
+	return newMessage(payload, headers)
+}
+
+func newMessage(payload []byte, headers map[string]string) (*Message, error) {
+	id := make([]byte, 16)
+	if _, err := rand.Read(id); err != nil {
+		return nil, fmt.Errorf("generating message id failed: %v", err)
+	}
+
+	return &Message{
+		ID:         hex.EncodeToString(id),
+		EnqueuedAt: time.Now(),
+		Headers:    headers,
+		Payload:    payload,
+	}, nil
+}
+
+// MarshalBinary implements encoding.BinaryMarshaler.
+func (m *Message) MarshalBinary() ([]byte, error) {
+	keys := make([]string, 0, len(m.Headers))
+	for key := range m.Headers {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	buffer := bytes.Buffer{}
+	buffer.Write(envelopeMagic)
+	writeUint64(&buffer, uint64(m.EnqueuedAt.UnixNano()))
+	writeUint64(&buffer, uint64(unixNano(m.ExpiresAt)))
+	writeBytes(&buffer, []byte(m.ID))
+	writeUint64(&buffer, uint64(len(keys)))
+	for _, key := range keys {
+		writeBytes(&buffer, []byte(key))
+		writeBytes(&buffer, []byte(m.Headers[key]))
+	}
+	buffer.Write(m.Payload)
+	return buffer.Bytes(), nil
+}
+
+// UnmarshalBinary implements encoding.BinaryUnmarshaler.
+func (m *Message) UnmarshalBinary(data []byte) error {
+	*m = Message{}
+	if !bytes.HasPrefix(data, envelopeMagic) {
+		return errors.New("message envelope is missing")
+	}
+
+	reader := bytes.NewReader(data[len(envelopeMagic):])
+	enqueuedAt, err := readUint64(reader)
+	if err != nil {
+		return err
+	}
+	m.EnqueuedAt = time.Unix(0, int64(enqueuedAt))
+
+	expiresAt, err := readUint64(reader)
+	if err != nil {
+		return err
+	}
+	m.ExpiresAt = fromUnixNano(int64(expiresAt))
+
+	id, err := readBytes(reader)
+	if err != nil {
+		return err
+	}
+	m.ID = string(id)
+
+	count, err := readUint64(reader)
+	if err != nil {
+		return err
+	}
+	if count > 0 {
+		m.Headers = make(map[string]string)
+	}
+	for index := uint64(0); index < count; index++ {
+		key, err := readBytes(reader)
+		if err != nil {
+			return err
+		}
+		value, err := readBytes(reader)
+		if err != nil {
+			return err
+		}
+		m.Headers[string(key)] = string(value)
+	}
+
+	m.Payload = make([]byte, reader.Len())
+	_, _ = reader.Read(m.Payload)
+	return nil
+}
+
+// SetEnvelope sets whether the elements of the queue are wrapped in a message envelope. The envelope
+// carries an id, the enqueue time, an optional expiry and headers. It's required by EnqueueMessage and
+// EnqueueModelWithTTL. If it's enabled, all enqueued models are wrapped - otherwise the stored values
+// are never interpreted. All instances on the same bucket have to use the same setting. It should be
+// called before the queue is used.
+func (q *Queue) SetEnvelope(enabled bool) {
+	q.envelope = enabled
+}
+
+// EnqueueMessage wraps the provided model and headers into a message envelope and puts it to the back
+// of the queue. If the queue is full, the call blocks until an element is dequeued. The id of the message
+// is returned. The message can be dequeued with DequeueMessage as well as with all other dequeue calls.
+// If the queue has no envelope, ErrNoEnvelope is returned.
+func (q *Queue) EnqueueMessage(model encoding.BinaryMarshaler, headers map[string]string) (string, error) {
+	if !q.envelope {
+		return "", ErrNoEnvelope
+	}
+	message, err := NewMessage(model, headers)
+	if err != nil {
+		return "", err
+	}
+	if err := q.EnqueueModel(message); err != nil {
+		return "", err
+	}
+	return message.ID, nil
+}
+
+// DequeueMessage gets the message from the front of the queue, unmarshals its payload into the provided
+// model and removes it. The message is returned with all its metadata. If the queue has no envelope, the
+// message only holds the payload and the delivery attempts. If the queue is empty the call blocks until
+// an element is enqueued or the provided context is done.
+func (q *Queue) DequeueMessage(ctx context.Context, model encoding.BinaryUnmarshaler) (*Message, error) {
+	message := (*Message)(nil)
+	err := popOrWait(ctx, q.session, q.name, PositionFront, 1, true, q.ready, q.expiry(), func(tx *bolt.Tx, keys, values [][]byte) (err error) {
+		attempts, err := takeAttempts(tx, q.name, keys[0])
+		if err != nil {
+			return err
+		}
+		if message, err = unmarshalMessage(values[0], q.envelope); err != nil {
+			return fmt.Errorf("unmarshaling failed: %v", err)
+		}
+		message.Attempts = attempts + 1
+		if err := model.UnmarshalBinary(message.Payload); err != nil {
+			return unmarshalError(err, q.name, keys[0])
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return message, nil
+}
+
+// envelopedModel wraps a model into a new message envelope when it's marshaled. Models that are
+// messages already are marshaled as they are.
+type envelopedModel struct {
+	model encoding.BinaryMarshaler
+}
+
+func (em envelopedModel) MarshalBinary() ([]byte, error) {
+	if message, ok := em.model.(*Message); ok {
+		return message.MarshalBinary()
+	}
+
+	payload, err := em.model.MarshalBinary()
+	if err != nil {
+		return nil, err
+	}
+	message, err := newMessage(payload, nil)
+	if err != nil {
+		return nil, err
+	}
+	return message.MarshalBinary()
+}
+
+// envelopeOf returns the provided model wrapped into a message envelope, if the provided envelope flag
+// is set. Otherwise, the model is returned as it is.
+func envelopeOf(model encoding.BinaryMarshaler, envelope bool) encoding.BinaryMarshaler {
+	if !envelope {
+		return model
+	}
+	return envelopedModel{model: model}
+}
+
+// envelop behaves like envelopeOf, but wraps all the provided models.
+func envelop(models []encoding.BinaryMarshaler, envelope bool) []encoding.BinaryMarshaler {
+	if !envelope {
+		return models
+	}
+	enveloped := make([]encoding.BinaryMarshaler, len(models))
+	for index, model := range models {
+		enveloped[index] = envelopeOf(model, envelope)
+	}
+	return enveloped
+}
+
+// unmarshalElement unmarshals the provided value into the provided model. If the envelope flag is set,
+// the value is expected to be a message and only its payload is unmarshaled - unless the model is a
+// message itself. Without the envelope, the value is passed to the model as it is. A message model only
+// gets the payload in that case.
+func unmarshalElement(model encoding.BinaryUnmarshaler, value []byte, envelope bool) error {
+	if message, ok := model.(*Message); ok {
+		result, err := unmarshalMessage(value, envelope)
+		if err != nil {
+			return err
+		}
+		*message = *result
+		return nil
+	}
+	if !envelope {
+		return model.UnmarshalBinary(value)
+	}
+
+	message := &Message{}
+	if err := message.UnmarshalBinary(value); err != nil {
+		return err
+	}
+	return model.UnmarshalBinary(message.Payload)
+}
+
+// unmarshalMessage returns the message of the provided value. If the envelope flag isn't set, the value
+// becomes the payload of a message without metadata.
+// splitEnvelope splits the provided value into its message envelope and its payload. If the value isn't
+// wrapped in an envelope, the envelope part is nil. It's only meant for values that are known to start
+// with a fixed header byte other than the one of the envelope - like checksummed or encrypted ones.
+func splitEnvelope(value []byte) ([]byte, []byte) {
+	if bytes.HasPrefix(value, envelopeMagic) {
+		message := &Message{}
+		if err := message.UnmarshalBinary(value); err == nil {
+			split := len(value) - len(message.Payload)
+			return value[:split], value[split:]
+		}
+	}
+	return nil, value
+}
+
+func unmarshalMessage(value []byte, envelope bool) (*Message, error) {
+	message := &Message{}
+	if !envelope {
+		message.Payload = append([]byte{}, value...)
+		return message, nil
+	}
+	if err := message.UnmarshalBinary(value); err != nil {
+		return nil, err
+	}
+	return message, nil
+}
+
+// unixNano returns the provided time in nanoseconds since the epoch or zero for the zero time.
+func unixNano(t time.Time) int64 {
+	if t.IsZero() {
+		return 0
+	}
+	return t.UnixNano()
+}
+
+// fromUnixNano is the reverse of unixNano.
+func fromUnixNano(nanos int64) time.Time {
+	if nanos == 0 {
+		return time.Time{}
+	}
+	return time.Unix(0, nanos)
+}
+
+func writeUint64(buffer *bytes.Buffer, value uint64) {
+	data := make([]byte, 8)
+	binary.BigEndian.PutUint64(data, value)
+	buffer.Write(data)
+}
+
+func writeBytes(buffer *bytes.Buffer, value []byte) {
+	writeUint64(buffer, uint64(len(value)))
+	buffer.Write(value)
+}
+
+func readUint64(reader *bytes.Reader) (uint64, error) {
+	data := make([]byte, 8)
+	if n, _ := reader.Read(data); n != len(data) {
+		return 0, errors.New("message envelope is too short")
+	}
+	return binary.BigEndian.Uint64(data), nil
+}
+
+func readBytes(reader *bytes.Reader) ([]byte, error) {
+	length, err := readUint64(reader)
+	if err != nil {
+		return nil, err
+	}
+	if length > uint64(reader.Len()) {
+		return nil, errors.New("message envelope is too short")
+	}
+	data := make([]byte, length)
+	_, _ = reader.Read(data)
+	return data, nil
+}
@@ -0,0 +1,122 @@
+package boltx_test
+
+import (
+	"context"
+	"encoding"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/simia-tech/boltx"
+)
+
+func TestMessageMarshaling(t *testing.T) {
+	message := &boltx.Message{
+		ID:         "id",
+		EnqueuedAt: time.Unix(0, 1500000000000000000),
+		ExpiresAt:  time.Unix(0, 1500000060000000000),
+		Headers:    map[string]string{"trace-id": "123", "source": "test"},
+		Payload:    []byte("payload"),
+	}
+
+	data, err := message.MarshalBinary()
+	require.NoError(t, err)
+
+	result := &boltx.Message{}
+	require.NoError(t, result.UnmarshalBinary(data))
+	assert.Equal(t, message, result)
+
+	assert.Error(t, result.UnmarshalBinary(data[:10]))
+
+	assert.Error(t, result.UnmarshalBinary([]byte("plain")))
+}
+
+func TestQueueMessageQueueing(t *testing.T) {
+	db, tearDown := setUpTestDB(t)
+	defer tearDown()
+
+	queue := boltx.NewQueue(db, []byte("test"))
+	queue.SetEnvelope(true)
+
+	id, err := queue.EnqueueMessage(&model{field: "one"}, map[string]string{"trace-id": "123"})
+	require.NoError(t, err)
+	assert.Len(t, id, 32)
+	require.NoError(t, queue.EnqueueModel(&model{field: "two"}))
+	_, err = queue.EnqueueMessage(&model{field: "three"}, nil)
+	require.NoError(t, err)
+
+	value := &model{}
+	message, err := queue.DequeueMessage(context.Background(), value)
+	require.NoError(t, err)
+	assert.Equal(t, &model{field: "one"}, value)
+	assert.Equal(t, id, message.ID)
+	assert.Equal(t, map[string]string{"trace-id": "123"}, message.Headers)
+	assert.Equal(t, 1, message.Attempts)
+	assert.WithinDuration(t, time.Now(), message.EnqueuedAt, time.Second)
+
+	message, err = queue.DequeueMessage(context.Background(), value)
+	require.NoError(t, err)
+	assert.Equal(t, &model{field: "two"}, value)
+	assert.Len(t, message.ID, 32)
+	assert.Nil(t, message.Headers)
+
+	require.NoError(t, queue.DequeueModel(value))
+	assert.Equal(t, &model{field: "three"}, value)
+}
+
+func TestQueueMessageAttempts(t *testing.T) {
+	db, tearDown := setUpTestDB(t)
+	defer tearDown()
+
+	queue := boltx.NewQueue(db, []byte("test"))
+	queue.SetEnvelope(true)
+	_, err := queue.EnqueueMessage(&model{field: "test"}, nil)
+	require.NoError(t, err)
+
+	receipt, err := queue.ReceiveModel(context.Background(), &model{}, time.Minute)
+	require.NoError(t, err)
+	require.NoError(t, queue.Nack(receipt))
+
+	message, err := queue.DequeueMessage(context.Background(), &model{})
+	require.NoError(t, err)
+	assert.Equal(t, 2, message.Attempts)
+}
+
+func TestQueueChanWithMessages(t *testing.T) {
+	db, tearDown := setUpTestDB(t)
+	defer tearDown()
+
+	queue := boltx.NewQueue(db, []byte("test"))
+	queue.SetEnvelope(true)
+	id, err := queue.EnqueueMessage(&model{field: "test"}, map[string]string{"key": "value"})
+	require.NoError(t, err)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	delivery := <-queue.Chan(ctx, boltx.ChanOptions{
+		Factory: func() encoding.BinaryUnmarshaler { return &model{} },
+	})
+	require.NoError(t, delivery.Err)
+	assert.Equal(t, &model{field: "test"}, delivery.Model)
+	assert.Equal(t, id, delivery.Message.ID)
+	assert.Equal(t, map[string]string{"key": "value"}, delivery.Message.Headers)
+}
+
+func TestQueueMessageWithoutEnvelope(t *testing.T) {
+	db, tearDown := setUpTestDB(t)
+	defer tearDown()
+
+	queue := boltx.NewQueue(db, []byte("test"))
+	_, err := queue.EnqueueMessage(&model{field: "test"}, nil)
+	assert.Equal(t, boltx.ErrNoEnvelope, err)
+
+	require.NoError(t, queue.EnqueueModel(&model{field: "test"}))
+	value := &model{}
+	message, err := queue.DequeueMessage(context.Background(), value)
+	require.NoError(t, err)
+	assert.Equal(t, &model{field: "test"}, value)
+	assert.Equal(t, &boltx.Message{Payload: []byte("test"), Attempts: 1}, message)
+}
@@ -7,9 +7,23 @@ import (
 	"github.com/boltdb/bolt"
 )
 
+// ModelFactory defines a function that returns a new instance of a model.
+type ModelFactory func() encoding.BinaryUnmarshaler
+
+// factoryOf returns a factory that always returns the provided model.
+func factoryOf(model encoding.BinaryUnmarshaler) ModelFactory {
+	return func() encoding.BinaryUnmarshaler { return model }
+}
+
 // PutModel marshals the provided model and stores it in the provided bucket under the provided key.
 func PutModel(bucket *bolt.Bucket, key []byte, model encoding.BinaryMarshaler) error {
-	value, err := model.MarshalBinary()
+	return PutValue(bucket, key, model, BinaryCodec)
+}
+
+// PutValue marshals the provided value with the provided codec and stores it in the provided bucket
+// under the provided key.
+func PutValue(bucket *bolt.Bucket, key []byte, v interface{}, codec Codec) error {
+	value, err := codec.Marshal(v)
 	if err != nil {
 		return fmt.Errorf("marshaling failed: %v", err)
 	}
@@ -24,13 +38,20 @@ func PutModel(bucket *bolt.Bucket, key []byte, model encoding.BinaryMarshaler) e
 // GetModel loads the value from the provided bucket at the provided key and unmarshals it into the
 // provided model. If the value was found, true is returned. False otherwise.
 func GetModel(bucket *bolt.Bucket, key []byte, model encoding.BinaryUnmarshaler) (bool, error) {
+	return GetValue(bucket, key, model, BinaryCodec)
+}
+
+// GetValue loads the value from the provided bucket at the provided key and unmarshals it with the
+// provided codec into v. If the value was found, true is returned. False otherwise. A checksum mismatch
+// is returned without the bucket name. Use GetValueFromBucket to get it included.
+func GetValue(bucket *bolt.Bucket, key []byte, v interface{}, codec Codec) (bool, error) {
 	value := bucket.Get(key)
 	if len(value) == 0 {
 		return false, nil
 	}
 
-	if err := model.UnmarshalBinary(value); err != nil {
-		return false, fmt.Errorf("unmarshaling failed: %v", err)
+	if err := unmarshalValue(codec, nil, key, value, v); err != nil {
+		return false, err
 	}
 
 	return true, nil
@@ -39,7 +60,12 @@ func GetModel(bucket *bolt.Bucket, key []byte, model encoding.BinaryUnmarshaler)
 // PutModelInBucket marshals the provided model, creates the bucket with the provided name if it's
 // not existing and stores the marshalled model under the provided key.
 func PutModelInBucket(db *bolt.DB, name, key []byte, model encoding.BinaryMarshaler) error {
-	value, err := model.MarshalBinary()
+	return PutValueInBucket(db, name, key, model, BinaryCodec)
+}
+
+// PutValueInBucket behaves like PutModelInBucket, but marshals the provided value with the provided codec.
+func PutValueInBucket(db *bolt.DB, name, key []byte, v interface{}, codec Codec) error {
+	value, err := codec.Marshal(v)
 	if err != nil {
 		return fmt.Errorf("marshaling failed: %v", err)
 	}
@@ -49,13 +75,18 @@ func PutModelInBucket(db *bolt.DB, name, key []byte, model encoding.BinaryMarsha
 // GetModelFromBucket loads the value from the provided bucket at the provided key and unmarshals it
 // into the provided model. If the bucket and the value was found, true is returned. False otherwise.
 func GetModelFromBucket(db *bolt.DB, name, key []byte, model encoding.BinaryUnmarshaler) (bool, error) {
+	return GetValueFromBucket(db, name, key, model, BinaryCodec)
+}
+
+// GetValueFromBucket behaves like GetModelFromBucket, but unmarshals the value with the provided codec.
+func GetValueFromBucket(db *bolt.DB, name, key []byte, v interface{}, codec Codec) (bool, error) {
 	value := GetFromBucket(db, name, key)
 	if value == nil {
 		return false, nil
 	}
 
-	if err := model.UnmarshalBinary(value); err != nil {
-		return false, fmt.Errorf("unmarshaling failed: %v", err)
+	if err := unmarshalValue(codec, name, key, value, v); err != nil {
+		return false, err
 	}
 
 	return true, nil
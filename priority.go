@@ -0,0 +1,135 @@
+package boltx
+
+import (
+	"context"
+	"encoding"
+	"encoding/binary"
+	"fmt"
+	"math"
+
+	"github.com/boltdb/bolt"
+)
+
+// PriorityQueue defines a priority queue on a bucket. Elements with a higher priority are dequeued
+// first, elements with the same priority in the order they were enqueued. It's persistent and safe
+// to use with multiple goroutines.
+//
+//   queue := boltx.NewPriorityQueue(db, []byte("priority-queue-test"))
+//
+//   queue.EnqueueModel(1, &model{"low"})
+//   queue.EnqueueModel(9, &model{"high"})
+//
+//   model := &model{}
+//   queue.DequeueModel(model)
+//
+//   log.Println(model) // high
+type PriorityQueue struct {
+	db      *bolt.DB
+	name    []byte
+	session *Session
+}
+
+// NewPriorityQueue initializes a priority queue in the bucket with the provided name.
+func NewPriorityQueue(db *bolt.DB, name []byte) *PriorityQueue {
+	return &PriorityQueue{
+		db:      db,
+		name:    name,
+		session: NewSession(db),
+	}
+}
+
+// EnqueueModel puts the provided model with the provided priority into the queue.
+func (pq *PriorityQueue) EnqueueModel(priority int, model encoding.BinaryMarshaler) error {
+	value, err := model.MarshalBinary()
+	if err != nil {
+		return fmt.Errorf("marshaling failed: %v", err)
+	}
+
+	return pq.session.Update(func(tx *bolt.Tx) error {
+		if err := PushWithPriority(tx, pq.name, priority, value); err != nil {
+			return err
+		}
+		pq.session.broadcastOnCommit(tx, pq.name)
+		return nil
+	})
+}
+
+// DequeueModel gets the value with the highest priority, unmarshals it into the provided model and
+// removes it. If the queue is empty the call blocks until an element is enqueued.
+func (pq *PriorityQueue) DequeueModel(model encoding.BinaryUnmarshaler) error {
+	return pq.DequeueModelContext(context.Background(), model)
+}
+
+// DequeueModelContext behaves like DequeueModel, but stops waiting for an element if the provided
+// context is done. In that case, the context's error is returned.
+func (pq *PriorityQueue) DequeueModelContext(ctx context.Context, model encoding.BinaryUnmarshaler) error {
+	return PopModelOrWaitContext(ctx, pq.session, pq.name, PositionFront, model)
+}
+
+// DequeueModels gets up to n values with the highest priorities, unmarshals them into models created
+// by the provided factory and removes them in a single transaction. If the queue is empty the call
+// blocks until an element is enqueued.
+func (pq *PriorityQueue) DequeueModels(n int, factory ModelFactory) ([]encoding.BinaryUnmarshaler, error) {
+	return pq.DequeueModelsContext(context.Background(), n, factory)
+}
+
+// DequeueModelsContext behaves like DequeueModels, but stops waiting for an element if the provided
+// context is done. In that case, the context's error is returned.
+func (pq *PriorityQueue) DequeueModelsContext(
+	ctx context.Context,
+	n int,
+	factory ModelFactory,
+) ([]encoding.BinaryUnmarshaler, error) {
+	return PopModelsOrWaitContext(ctx, pq.session, pq.name, PositionFront, n, factory)
+}
+
+// TryDequeueModel gets the value with the highest priority, unmarshals it into the provided model
+// and removes it. If the queue is empty, ErrEmpty is returned immediately.
+func (pq *PriorityQueue) TryDequeueModel(model encoding.BinaryUnmarshaler) error {
+	_, err := popModelsOrWait(context.Background(), pq.session, pq.name, PositionFront, 1, false, factoryOf(model), nil)
+	return err
+}
+
+// PeekModel gets the value with the highest priority and unmarshals it into the provided model
+// without removing it. If the queue is empty, ErrEmpty is returned.
+func (pq *PriorityQueue) PeekModel(model encoding.BinaryUnmarshaler) error {
+	return pq.db.View(func(tx *bolt.Tx) error {
+		return PeekModel(tx, pq.name, PositionFront, model)
+	})
+}
+
+// Size returns the number of elements in the queue.
+func (pq *PriorityQueue) Size() int {
+	return BucketSize(pq.db, pq.name)
+}
+
+// Close closes the queue. All calls that are waiting for an element return ErrClosed, as well as all
+// further enqueue and dequeue calls.
+func (pq *PriorityQueue) Close() error {
+	return pq.session.Close()
+}
+
+// PushWithPriority inserts the provided value with the provided priority into the provided bucket. The
+// key is composed of the inverted priority and the bucket's sequence, so the value with the highest
+// priority can be taken from PositionFront.
+func PushWithPriority(tx *bolt.Tx, name []byte, priority int, value []byte) error {
+	bucket, err := tx.CreateBucketIfNotExists(name)
+	if err != nil {
+		return err
+	}
+
+	sequence, err := bucket.NextSequence()
+	if err != nil {
+		return err
+	}
+
+	return bucket.Put(priorityKey(priority, sequence), value)
+}
+
+func priorityKey(priority int, sequence uint64) []byte {
+	key := make([]byte, 16)
+	// flipping all bits except the sign bit maps the highest priority to the lowest unsigned value.
+	binary.BigEndian.PutUint64(key[:8], uint64(int64(priority))^math.MaxInt64)
+	binary.BigEndian.PutUint64(key[8:], sequence)
+	return key
+}
@@ -0,0 +1,86 @@
+package boltx_test
+
+import (
+	"context"
+	"encoding"
+	"testing"
+	"time"
+
+	"github.com/boltdb/bolt"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/simia-tech/boltx"
+)
+
+func TestPriorityQueueOrdering(t *testing.T) {
+	db, tearDown := setUpTestDB(t)
+	defer tearDown()
+
+	queue := boltx.NewPriorityQueue(db, []byte("test"))
+
+	assert.Error(t, queue.EnqueueModel(0, &model{field: "invalid"}))
+	require.NoError(t, queue.EnqueueModel(0, &model{field: "three"}))
+	require.NoError(t, queue.EnqueueModel(-5, &model{field: "five"}))
+	require.NoError(t, queue.EnqueueModel(10, &model{field: "one"}))
+	require.NoError(t, queue.EnqueueModel(0, &model{field: "four"}))
+	require.NoError(t, queue.EnqueueModel(10, &model{field: "two"}))
+	assert.Equal(t, 5, queue.Size())
+
+	value := &model{}
+	require.NoError(t, queue.PeekModel(value))
+	assert.Equal(t, "one", value.field)
+
+	for _, expected := range []string{"one", "two", "three"} {
+		require.NoError(t, queue.DequeueModel(value))
+		assert.Equal(t, expected, value.field)
+	}
+
+	models, err := queue.DequeueModels(5, func() encoding.BinaryUnmarshaler { return &model{} })
+	require.NoError(t, err)
+	assert.Equal(t, []encoding.BinaryUnmarshaler{&model{field: "four"}, &model{field: "five"}}, models)
+
+	assert.Equal(t, boltx.ErrEmpty, queue.TryDequeueModel(value))
+	assert.Equal(t, boltx.ErrEmpty, queue.PeekModel(value))
+}
+
+func TestPriorityQueueDequeueOnEmpty(t *testing.T) {
+	db, tearDown := setUpTestDB(t)
+	defer tearDown()
+
+	queue := boltx.NewPriorityQueue(db, []byte("test"))
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	assert.Equal(t, context.DeadlineExceeded, queue.DequeueModelContext(ctx, &model{}))
+
+	values := make(chan *model)
+	go func() {
+		value := &model{}
+		require.NoError(t, queue.DequeueModel(value))
+		values <- value
+	}()
+
+	time.Sleep(20 * time.Millisecond)
+	require.NoError(t, queue.EnqueueModel(1, &model{field: "test"}))
+
+	assert.Equal(t, &model{field: "test"}, <-values)
+}
+
+func TestPushWithPriority(t *testing.T) {
+	db, tearDown := setUpTestDB(t)
+	defer tearDown()
+
+	name := []byte("test")
+
+	require.NoError(t, db.Update(func(tx *bolt.Tx) error {
+		require.NoError(t, boltx.PushWithPriority(tx, name, -1, []byte("low")))
+		require.NoError(t, boltx.PushWithPriority(tx, name, 1, []byte("high")))
+
+		assert.Equal(t, "high", string(boltx.Pop(tx, name, boltx.PositionFront)))
+		assert.Equal(t, "low", string(boltx.Pop(tx, name, boltx.PositionFront)))
+
+		assert.Error(t, boltx.PushWithPriority(tx, []byte(""), 0, []byte("test")))
+		return nil
+	}))
+}
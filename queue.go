@@ -1,7 +1,10 @@
 package boltx
 
 import (
+	"context"
 	"encoding"
+	"fmt"
+	"time"
 
 	"github.com/boltdb/bolt"
 )
@@ -18,36 +21,314 @@ import (
 //
 //   log.Println(model)
 type Queue struct {
-	db      *bolt.DB
-	name    []byte
-	session *Session
+	db          *bolt.DB
+	name        []byte
+	capacity    int
+	maxAttempts int
+	keyScheme   KeyScheme
+	codec       Codec
+	session     *Session
+
+	envelope             bool
+	expiredToDeadLetters bool
 }
 
 // NewQueue initializes a queue in the bucket with the provided name.
 func NewQueue(db *bolt.DB, name []byte) *Queue {
+	return NewBoundedQueue(db, name, 0)
+}
+
+// NewBoundedQueue initializes a queue in the bucket with the provided name that holds at most capacity
+// elements. If the queue is full, enqueue calls block until an element is dequeued. Due scheduled
+// elements wait until there's space. Elements that are put back - like failed or undelivered ones - are
+// exempt from the limit, since they've held a place in the queue before. A capacity less than one means
+// no limit.
+func NewBoundedQueue(db *bolt.DB, name []byte, capacity int) *Queue {
+	return newQueue(db, name, capacity, NewSession(db))
+}
+
+func newQueue(db *bolt.DB, name []byte, capacity int, session *Session) *Queue {
 	return &Queue{
-		db:      db,
-		name:    name,
-		session: NewSession(db),
+		db:        db,
+		name:      name,
+		capacity:  capacity,
+		keyScheme: Uint64DequeKeyScheme,
+		codec:     BinaryCodec,
+		session:   session,
 	}
 }
 
-// EnqueueModel puts the provided model to the back of the queue.
+// SetKeyScheme sets the key scheme that generates the keys of the queue's elements. The default is
+// Uint64DequeKeyScheme. It should be called before the queue is used.
+func (q *Queue) SetKeyScheme(scheme KeyScheme) {
+	q.keyScheme = scheme
+}
+
+// SetCodec sets the codec that is used by the value functions of the queue. The default is BinaryCodec.
+// It should be called before the queue is used.
+func (q *Queue) SetCodec(codec Codec) {
+	q.codec = codec
+}
+
+// EnqueueValue marshals the provided value with the queue's codec and puts it to the back of the queue.
+// If the queue is full, the call blocks until an element is dequeued.
+func (q *Queue) EnqueueValue(v interface{}) error {
+	return q.EnqueueModel(modelOf(v, q.codec))
+}
+
+// DequeueValue gets the value from the front of the queue, unmarshals it with the queue's codec into v
+// and removes it. If the queue is empty the call blocks until an element is enqueued.
+func (q *Queue) DequeueValue(v interface{}) error {
+	return q.DequeueValueContext(context.Background(), v)
+}
+
+// DequeueValueContext behaves like DequeueValue, but stops waiting for an element if the provided
+// context is done. In that case, the context's error is returned.
+func (q *Queue) DequeueValueContext(ctx context.Context, v interface{}) error {
+	return q.DequeueModelContext(ctx, modelOf(v, q.codec))
+}
+
+// EnqueueModel puts the provided model to the back of the queue. If the queue is full, the call
+// blocks until an element is dequeued.
 func (q *Queue) EnqueueModel(model encoding.BinaryMarshaler) error {
+	return q.EnqueueModelContext(context.Background(), model)
+}
+
+// EnqueueModelContext behaves like EnqueueModel, but stops waiting for free space if the provided
+// context is done. In that case, the context's error is returned.
+func (q *Queue) EnqueueModelContext(ctx context.Context, model encoding.BinaryMarshaler) error {
+	return q.enqueueModels(ctx, []encoding.BinaryMarshaler{model}, true)
+}
+
+// TryEnqueueModel puts the provided model to the back of the queue. If the queue is full, ErrFull
+// is returned immediately.
+func (q *Queue) TryEnqueueModel(model encoding.BinaryMarshaler) error {
+	return q.enqueueModels(context.Background(), []encoding.BinaryMarshaler{model}, false)
+}
+
+// EnqueueModels puts all the provided models to the back of the queue in a single transaction. If
+// the queue hasn't enough space left for all models, the call blocks until enough elements are
+// dequeued.
+func (q *Queue) EnqueueModels(models []encoding.BinaryMarshaler) error {
+	return q.enqueueModels(context.Background(), models, true)
+}
+
+func (q *Queue) enqueueModels(ctx context.Context, models []encoding.BinaryMarshaler, wait bool) error {
+	return pushModelsOrWait(ctx, q.session, q.name, PositionBack, envelop(models, q.envelope), q.keyScheme, q.capacity, wait)
+}
+
+// EnqueueModelTx puts the provided model to the back of the queue within the provided transaction. This
+// way, enqueuing can be part of a larger transaction. Waiting consumers are woken up once the transaction
+// is committed. If the queue is full, ErrFull is returned immediately.
+func (q *Queue) EnqueueModelTx(tx *bolt.Tx, model encoding.BinaryMarshaler) error {
+	value, err := envelopeOf(model, q.envelope).MarshalBinary()
+	if err != nil {
+		return fmt.Errorf("marshaling failed: %v", err)
+	}
+	return tryPush(tx, q.name, PositionBack, [][]byte{value}, q.keyScheme, q.capacity, q.session)
+}
+
+// EnqueueModelAt puts the provided model to the back of the queue once the provided time is
+// reached. Until then, the element is invisible to all dequeue and peek calls. Scheduled elements
+// don't count against the capacity of the queue before they're due.
+func (q *Queue) EnqueueModelAt(due time.Time, model encoding.BinaryMarshaler) error {
+	if !due.After(time.Now()) {
+		return q.EnqueueModel(model)
+	}
+
+	value, err := envelopeOf(model, q.envelope).MarshalBinary()
+	if err != nil {
+		return fmt.Errorf("marshaling failed: %v", err)
+	}
+
 	return q.session.Update(func(tx *bolt.Tx) error {
-		return PushModelAndSignal(tx, q.name, PositionBack, model, DefaultUint64DequeKey, q.session)
+		if _, err := pushScheduled(tx, q.name, due, value); err != nil {
+			return err
+		}
+		q.session.broadcastOnCommit(tx, q.name)
+		return nil
 	})
 }
 
+// EnqueueModelAfter puts the provided model to the back of the queue once the provided duration
+// has passed.
+func (q *Queue) EnqueueModelAfter(delay time.Duration, model encoding.BinaryMarshaler) error {
+	return q.EnqueueModelAt(time.Now().Add(delay), model)
+}
+
 // DequeueModel gets the value from the front of the deque, unmarshals it into the provided
 // model and removes it. If the deque is empty the call blocks until an element is enqueued.
 func (q *Queue) DequeueModel(model encoding.BinaryUnmarshaler) error {
-	return q.session.Update(func(tx *bolt.Tx) error {
-		return PopModelOrWait(tx, q.name, PositionFront, model, q.session)
+	return q.DequeueModelContext(context.Background(), model)
+}
+
+// DequeueModelContext behaves like DequeueModel, but stops waiting for an element if the provided
+// context is done. In that case, the context's error is returned.
+func (q *Queue) DequeueModelContext(ctx context.Context, model encoding.BinaryUnmarshaler) error {
+	_, err := q.dequeueModels(ctx, 1, true, factoryOf(model))
+	return err
+}
+
+// DequeueModels gets up to n values from the front of the queue, unmarshals them into models created
+// by the provided factory and removes them in a single transaction. If the queue is empty the call
+// blocks until an element is enqueued.
+func (q *Queue) DequeueModels(n int, factory ModelFactory) ([]encoding.BinaryUnmarshaler, error) {
+	return q.DequeueModelsContext(context.Background(), n, factory)
+}
+
+// DequeueModelsContext behaves like DequeueModels, but stops waiting for an element if the provided
+// context is done. In that case, the context's error is returned.
+func (q *Queue) DequeueModelsContext(ctx context.Context, n int, factory ModelFactory) ([]encoding.BinaryUnmarshaler, error) {
+	return q.dequeueModels(ctx, n, true, factory)
+}
+
+// TryDequeueModel gets the value from the front of the queue, unmarshals it into the provided
+// model and removes it. If the queue is empty, ErrEmpty is returned immediately.
+func (q *Queue) TryDequeueModel(model encoding.BinaryUnmarshaler) error {
+	_, err := q.dequeueModels(context.Background(), 1, false, factoryOf(model))
+	return err
+}
+
+// DequeueModelTx gets the value from the front of the queue, unmarshals it into the provided model and
+// removes it within the provided transaction. This way, the removal can be combined atomically with other
+// changes. If the queue is empty, ErrEmpty is returned immediately.
+func (q *Queue) DequeueModelTx(tx *bolt.Tx, model encoding.BinaryUnmarshaler) error {
+	if _, err := q.ready(tx); err != nil {
+		return err
+	}
+
+	key, value, dropped, err := popLive(tx, q.name, PositionFront, time.Now(), q.expiry())
+	if err != nil {
+		return err
+	}
+	if dropped > 0 || key != nil {
+		q.session.signalFreeOnCommit(tx, q.name)
+	}
+	if key == nil {
+		return ErrEmpty
+	}
+	return q.take(tx, key, value, model)
+}
+
+// ProcessFunc defines a function that processes a dequeued model within the dequeuing transaction.
+type ProcessFunc func(tx *bolt.Tx, model encoding.BinaryUnmarshaler) error
+
+// Process gets the value from the front of the queue, unmarshals it into the provided model and passes it
+// to the provided function together with the transaction that removes it. If the function returns an
+// error, the transaction is rolled back, the element stays at the front of the queue and the error is
+// returned. If the queue is empty the call blocks until an element is enqueued or the provided context is
+// done.
+func (q *Queue) Process(ctx context.Context, model encoding.BinaryUnmarshaler, fn ProcessFunc) error {
+	return popOrWait(ctx, q.session, q.name, PositionFront, 1, true, q.ready, q.expiry(), func(tx *bolt.Tx, keys, values [][]byte) error {
+		if err := q.take(tx, keys[0], values[0], model); err != nil {
+			return err
+		}
+		return fn(tx, model)
+	})
+}
+
+// take drops the delivery attempts of the popped element and unmarshals its value into the provided
+// model.
+func (q *Queue) take(tx *bolt.Tx, key, value []byte, model encoding.BinaryUnmarshaler) error {
+	if _, err := takeAttempts(tx, q.name, key); err != nil {
+		return err
+	}
+	if err := unmarshalElement(model, value, q.envelope); err != nil {
+		return unmarshalError(err, q.name, key)
+	}
+	return nil
+}
+
+func (q *Queue) dequeueModels(
+	ctx context.Context,
+	n int,
+	wait bool,
+	factory ModelFactory,
+) ([]encoding.BinaryUnmarshaler, error) {
+	models := []encoding.BinaryUnmarshaler(nil)
+	err := popOrWait(ctx, q.session, q.name, PositionFront, n, wait, q.ready, q.expiry(), func(tx *bolt.Tx, keys, values [][]byte) (err error) {
+		for _, key := range keys {
+			if _, err := takeAttempts(tx, q.name, key); err != nil {
+				return err
+			}
+		}
+		models, err = unmarshalModels(q.name, keys, values, factory, q.envelope)
+		return
+	})
+	return models, err
+}
+
+// PeekModel gets the value from the front of the queue and unmarshals it into the provided model
+// without removing it. If the queue is empty, ErrEmpty is returned.
+func (q *Queue) PeekModel(model encoding.BinaryUnmarshaler) error {
+	return q.db.View(func(tx *bolt.Tx) error {
+		name := q.name
+		key, value := peekLive(tx, name, PositionFront, time.Now(), q.envelope)
+		if key == nil {
+			name = scheduledName(q.name)
+			key, value = peekScheduled(tx, q.name, time.Now())
+		}
+		if key == nil {
+			return ErrEmpty
+		}
+
+		if err := unmarshalElement(model, value, q.envelope); err != nil {
+			return unmarshalError(err, name, key)
+		}
+
+		return nil
 	})
 }
 
-// Size returns the number of elements in the deque.
+// Size returns the number of elements in the queue including the scheduled ones.
 func (q *Queue) Size() int {
-	return BucketSize(q.db, q.name)
+	size := 0
+	_ = q.db.View(func(tx *bolt.Tx) error {
+		scheduled, _ := countScheduled(tx, q.name, time.Now())
+		size = bucketKeyCount(tx, q.name) + scheduled
+		return nil
+	})
+	return size
+}
+
+// ReadySize returns the number of elements in the queue that can be dequeued right now.
+func (q *Queue) ReadySize() int {
+	size := 0
+	_ = q.db.View(func(tx *bolt.Tx) error {
+		_, due := countScheduled(tx, q.name, time.Now())
+		size = bucketKeyCount(tx, q.name) + due
+		return nil
+	})
+	return size
+}
+
+// Close closes the queue. All calls that are waiting for an element or for free space return
+// ErrClosed, as well as all further enqueue and dequeue calls.
+func (q *Queue) Close() error {
+	return q.session.Close()
+}
+
+// ready moves all due elements from the schedule into the queue and returns elements with an
+// expired lease to the front.
+func (q *Queue) ready(tx *bolt.Tx) (time.Time, error) {
+	now := time.Now()
+
+	nextDue, scheduled, err := promoteScheduled(tx, q.name, q.keyScheme, q.capacity, now)
+	if err != nil {
+		return time.Time{}, err
+	}
+
+	nextDeadline, expired, err := q.requeueExpired(tx, now)
+	if err != nil {
+		return time.Time{}, err
+	}
+
+	if scheduled+expired > 1 {
+		q.session.broadcastOnCommit(tx, q.name)
+	}
+
+	if nextDue.IsZero() || (!nextDeadline.IsZero() && nextDeadline.Before(nextDue)) {
+		return nextDeadline, nil
+	}
+	return nextDue, nil
 }
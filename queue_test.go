@@ -1,9 +1,13 @@
 package boltx_test
 
 import (
+	"context"
+	"encoding"
+	"errors"
 	"testing"
 	"time"
 
+	"github.com/boltdb/bolt"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 
@@ -49,3 +53,347 @@ func TestQueueDequeueOnEmpty(t *testing.T) {
 
 	assert.Equal(t, &model{field: "test"}, <-values)
 }
+
+func TestQueueDequeueOnEmptyFromOtherInstance(t *testing.T) {
+	db, tearDown := setUpTestDB(t)
+	defer tearDown()
+
+	consumer := boltx.NewQueue(db, []byte("test"))
+	producer := boltx.NewQueue(db, []byte("test"))
+
+	values := make(chan *model)
+	go func() {
+		for index := 0; index < 2; index++ {
+			value := &model{}
+			require.NoError(t, consumer.DequeueModel(value))
+			values <- value
+		}
+	}()
+
+	time.Sleep(20 * time.Millisecond)
+	require.NoError(t, producer.EnqueueModel(&model{field: "one"}))
+	assert.Equal(t, &model{field: "one"}, <-values)
+
+	time.Sleep(20 * time.Millisecond)
+	session := boltx.NewSession(db)
+	require.NoError(t, session.Update(func(tx *bolt.Tx) error {
+		return boltx.PushModelAndSignal(tx, []byte("test"), boltx.PositionBack, &model{field: "two"}, boltx.DefaultUint64DequeKey, session)
+	}))
+	assert.Equal(t, &model{field: "two"}, <-values)
+}
+
+func TestQueueDequeueWithContext(t *testing.T) {
+	db, tearDown := setUpTestDB(t)
+	defer tearDown()
+
+	queue := boltx.NewQueue(db, []byte("test"))
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+
+	value := &model{}
+	assert.Equal(t, context.DeadlineExceeded, queue.DequeueModelContext(ctx, value))
+
+	require.NoError(t, queue.EnqueueModel(&model{field: "test"}))
+	require.NoError(t, queue.DequeueModelContext(context.Background(), value))
+	assert.Equal(t, &model{field: "test"}, value)
+}
+
+func TestQueueClose(t *testing.T) {
+	db, tearDown := setUpTestDB(t)
+	defer tearDown()
+
+	queue := boltx.NewQueue(db, []byte("test"))
+
+	errs := make(chan error)
+	go func() {
+		errs <- queue.DequeueModel(&model{})
+	}()
+
+	time.Sleep(20 * time.Millisecond)
+	require.NoError(t, queue.Close())
+
+	assert.Equal(t, boltx.ErrClosed, <-errs)
+	assert.Equal(t, boltx.ErrClosed, queue.EnqueueModel(&model{field: "test"}))
+}
+
+func TestQueueEnqueueInTransaction(t *testing.T) {
+	db, tearDown := setUpTestDB(t)
+	defer tearDown()
+
+	queue := boltx.NewBoundedQueue(db, []byte("test"), 1)
+
+	values := make(chan *model)
+	go func() {
+		value := &model{}
+		require.NoError(t, queue.DequeueModel(value))
+		values <- value
+	}()
+
+	time.Sleep(20 * time.Millisecond)
+	assert.Error(t, db.Update(func(tx *bolt.Tx) error {
+		require.NoError(t, queue.EnqueueModelTx(tx, &model{field: "rollback"}))
+		return errors.New("rollback")
+	}))
+	assert.Equal(t, 0, queue.Size())
+
+	require.NoError(t, db.Update(func(tx *bolt.Tx) error {
+		if err := queue.EnqueueModelTx(tx, &model{field: "test"}); err != nil {
+			return err
+		}
+		assert.Equal(t, boltx.ErrFull, queue.EnqueueModelTx(tx, &model{field: "test"}))
+		bucket, err := tx.CreateBucketIfNotExists([]byte("other"))
+		if err != nil {
+			return err
+		}
+		return bucket.Put([]byte("key"), []byte("value"))
+	}))
+
+	assert.Equal(t, &model{field: "test"}, <-values)
+}
+
+func TestQueueDequeueInTransaction(t *testing.T) {
+	db, tearDown := setUpTestDB(t)
+	defer tearDown()
+
+	queue := boltx.NewQueue(db, []byte("test"))
+	require.NoError(t, queue.EnqueueModel(&model{field: "test"}))
+
+	value := &model{}
+	assert.Error(t, db.Update(func(tx *bolt.Tx) error {
+		require.NoError(t, queue.DequeueModelTx(tx, value))
+		return errors.New("rollback")
+	}))
+	assert.Equal(t, 1, queue.Size())
+
+	require.NoError(t, db.Update(func(tx *bolt.Tx) error {
+		if err := queue.DequeueModelTx(tx, value); err != nil {
+			return err
+		}
+		assert.Equal(t, boltx.ErrEmpty, queue.DequeueModelTx(tx, value))
+		return nil
+	}))
+	assert.Equal(t, &model{field: "test"}, value)
+	assert.Equal(t, 0, queue.Size())
+}
+
+func TestQueueProcess(t *testing.T) {
+	db, tearDown := setUpTestDB(t)
+	defer tearDown()
+
+	queue := boltx.NewQueue(db, []byte("test"))
+
+	errs := make(chan error)
+	go func() {
+		errs <- queue.Process(context.Background(), &model{}, func(tx *bolt.Tx, value encoding.BinaryUnmarshaler) error {
+			return errors.New("handler failed")
+		})
+	}()
+
+	time.Sleep(20 * time.Millisecond)
+	require.NoError(t, queue.EnqueueModel(&model{field: "test"}))
+
+	assert.EqualError(t, <-errs, "handler failed")
+	assert.Equal(t, 1, queue.Size())
+
+	require.NoError(t, queue.Process(context.Background(), &model{}, func(tx *bolt.Tx, value encoding.BinaryUnmarshaler) error {
+		bucket, err := tx.CreateBucketIfNotExists([]byte("state"))
+		if err != nil {
+			return err
+		}
+		return bucket.Put([]byte("processed"), []byte(value.(*model).field))
+	}))
+
+	assert.Equal(t, 0, queue.Size())
+	assert.Equal(t, "test", string(boltx.GetFromBucket(db, []byte("state"), []byte("processed"))))
+}
+
+func TestQueueTryDequeue(t *testing.T) {
+	db, tearDown := setUpTestDB(t)
+	defer tearDown()
+
+	queue := boltx.NewQueue(db, []byte("test"))
+
+	value := &model{}
+	assert.Equal(t, boltx.ErrEmpty, queue.TryDequeueModel(value))
+
+	require.NoError(t, queue.EnqueueModel(&model{field: "test"}))
+	require.NoError(t, queue.TryDequeueModel(value))
+	assert.Equal(t, &model{field: "test"}, value)
+
+	assert.Equal(t, boltx.ErrEmpty, queue.TryDequeueModel(value))
+}
+
+func TestQueuePeek(t *testing.T) {
+	db, tearDown := setUpTestDB(t)
+	defer tearDown()
+
+	queue := boltx.NewQueue(db, []byte("test"))
+
+	value := &model{}
+	assert.Equal(t, boltx.ErrEmpty, queue.PeekModel(value))
+
+	require.NoError(t, queue.EnqueueModel(&model{field: "one"}))
+	require.NoError(t, queue.EnqueueModel(&model{field: "two"}))
+
+	require.NoError(t, queue.PeekModel(value))
+	assert.Equal(t, "one", value.field)
+	assert.Equal(t, 2, queue.Size())
+}
+
+func TestQueueBatchQueueing(t *testing.T) {
+	db, tearDown := setUpTestDB(t)
+	defer tearDown()
+
+	queue := boltx.NewQueue(db, []byte("test"))
+
+	assert.Error(t, queue.EnqueueModels([]encoding.BinaryMarshaler{&model{field: "one"}, &model{field: "invalid"}}))
+	assert.Equal(t, 0, queue.Size())
+
+	require.NoError(t, queue.EnqueueModels([]encoding.BinaryMarshaler{
+		&model{field: "one"}, &model{field: "two"}, &model{field: "three"},
+	}))
+	assert.Equal(t, 3, queue.Size())
+
+	factory := func() encoding.BinaryUnmarshaler { return &model{} }
+
+	models, err := queue.DequeueModels(2, factory)
+	require.NoError(t, err)
+	assert.Equal(t, []encoding.BinaryUnmarshaler{&model{field: "one"}, &model{field: "two"}}, models)
+
+	models, err = queue.DequeueModels(2, factory)
+	require.NoError(t, err)
+	assert.Equal(t, []encoding.BinaryUnmarshaler{&model{field: "three"}}, models)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	_, err = queue.DequeueModelsContext(ctx, 2, factory)
+	assert.Equal(t, context.DeadlineExceeded, err)
+}
+
+func TestQueueBatchDequeueOnEmpty(t *testing.T) {
+	db, tearDown := setUpTestDB(t)
+	defer tearDown()
+
+	queue := boltx.NewQueue(db, []byte("test"))
+
+	results := make(chan []encoding.BinaryUnmarshaler)
+	go func() {
+		models, err := queue.DequeueModels(0, func() encoding.BinaryUnmarshaler { return &model{} })
+		require.NoError(t, err)
+		results <- models
+	}()
+
+	time.Sleep(20 * time.Millisecond)
+	require.NoError(t, queue.EnqueueModels([]encoding.BinaryMarshaler{&model{field: "one"}, &model{field: "two"}}))
+
+	assert.Equal(t, []encoding.BinaryUnmarshaler{&model{field: "one"}, &model{field: "two"}}, <-results)
+}
+
+func TestBoundedQueue(t *testing.T) {
+	db, tearDown := setUpTestDB(t)
+	defer tearDown()
+
+	queue := boltx.NewBoundedQueue(db, []byte("test"), 2)
+
+	require.NoError(t, queue.EnqueueModel(&model{field: "one"}))
+	require.NoError(t, queue.TryEnqueueModel(&model{field: "two"}))
+	assert.Equal(t, boltx.ErrFull, queue.TryEnqueueModel(&model{field: "three"}))
+	assert.Equal(t, boltx.ErrFull, queue.EnqueueModels([]encoding.BinaryMarshaler{
+		&model{field: "three"}, &model{field: "four"}, &model{field: "five"},
+	}))
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	assert.Equal(t, context.DeadlineExceeded, queue.EnqueueModelContext(ctx, &model{field: "three"}))
+	assert.Equal(t, 2, queue.Size())
+
+	errs := make(chan error)
+	go func() {
+		errs <- queue.EnqueueModel(&model{field: "three"})
+	}()
+
+	time.Sleep(20 * time.Millisecond)
+	value := &model{}
+	require.NoError(t, queue.DequeueModel(value))
+	assert.Equal(t, "one", value.field)
+
+	require.NoError(t, <-errs)
+	assert.Equal(t, 2, queue.Size())
+}
+
+func TestBoundedQueueWithScheduledElements(t *testing.T) {
+	db, tearDown := setUpTestDB(t)
+	defer tearDown()
+
+	queue := boltx.NewBoundedQueue(db, []byte("test"), 1)
+	require.NoError(t, queue.EnqueueModelAfter(10*time.Millisecond, &model{field: "one"}))
+	require.NoError(t, queue.EnqueueModelAfter(10*time.Millisecond, &model{field: "two"}))
+	require.NoError(t, queue.EnqueueModel(&model{field: "now"}))
+	time.Sleep(20 * time.Millisecond)
+
+	value := &model{}
+	for _, expected := range []string{"now", "one", "two"} {
+		require.NoError(t, queue.DequeueModel(value))
+		assert.Equal(t, expected, value.field)
+		assert.Equal(t, 0, boltx.BucketSize(db, []byte("test")))
+	}
+
+	require.NoError(t, queue.TryEnqueueModel(&model{field: "one"}))
+	inTestBucket(t, db, func(bucket *bolt.Bucket) {
+		key, _ := bucket.Cursor().First()
+		require.NoError(t, bucket.Delete(key))
+	})
+	require.NoError(t, queue.TryEnqueueModel(&model{field: "two"}))
+	assert.Equal(t, boltx.ErrFull, queue.TryEnqueueModel(&model{field: "three"}))
+}
+
+func TestQueueScheduledQueueing(t *testing.T) {
+	db, tearDown := setUpTestDB(t)
+	defer tearDown()
+
+	queue := boltx.NewQueue(db, []byte("test"))
+
+	assert.Error(t, queue.EnqueueModelAfter(time.Minute, &model{field: "invalid"}))
+	require.NoError(t, queue.EnqueueModelAfter(time.Hour, &model{field: "later"}))
+	require.NoError(t, queue.EnqueueModelAfter(40*time.Millisecond, &model{field: "soon"}))
+	require.NoError(t, queue.EnqueueModelAt(time.Now().Add(-time.Second), &model{field: "now"}))
+
+	assert.Equal(t, 3, queue.Size())
+	assert.Equal(t, 1, queue.ReadySize())
+
+	value := &model{}
+	require.NoError(t, queue.TryDequeueModel(value))
+	assert.Equal(t, "now", value.field)
+
+	assert.Equal(t, boltx.ErrEmpty, queue.TryDequeueModel(value))
+	assert.Equal(t, boltx.ErrEmpty, queue.PeekModel(value))
+
+	start := time.Now()
+	require.NoError(t, queue.DequeueModel(value))
+	assert.Equal(t, "soon", value.field)
+	assert.True(t, time.Since(start) >= 30*time.Millisecond)
+
+	assert.Equal(t, 1, queue.Size())
+	assert.Equal(t, 0, queue.ReadySize())
+}
+
+func TestQueueScheduledDequeueOnEmpty(t *testing.T) {
+	db, tearDown := setUpTestDB(t)
+	defer tearDown()
+
+	queue := boltx.NewQueue(db, []byte("test"))
+
+	values := make(chan *model)
+	go func() {
+		value := &model{}
+		require.NoError(t, queue.DequeueModel(value))
+		values <- value
+	}()
+
+	time.Sleep(20 * time.Millisecond)
+	require.NoError(t, queue.EnqueueModelAfter(20*time.Millisecond, &model{field: "test"}))
+
+	assert.Equal(t, &model{field: "test"}, <-values)
+	assert.Equal(t, 0, queue.Size())
+}
@@ -0,0 +1,119 @@
+package boltx
+
+import (
+	"encoding/binary"
+	"time"
+
+	"github.com/boltdb/bolt"
+)
+
+// scheduledName returns the name of the bucket that holds the scheduled values of the bucket with
+// the provided name.
+func scheduledName(name []byte) []byte {
+	return append(append([]byte{}, name...), ":scheduled"...)
+}
+
+// pushScheduled inserts the provided value into the scheduled bucket of the bucket with the provided
+// name. The key is composed of the due time and the bucket's sequence, so the values are ordered by
+// their due time. The key is returned.
+func pushScheduled(tx *bolt.Tx, name []byte, due time.Time, value []byte) ([]byte, error) {
+	bucket, err := tx.CreateBucketIfNotExists(scheduledName(name))
+	if err != nil {
+		return nil, err
+	}
+
+	sequence, err := bucket.NextSequence()
+	if err != nil {
+		return nil, err
+	}
+
+	key := make([]byte, 16)
+	binary.BigEndian.PutUint64(key[:8], uint64(due.UnixNano()))
+	binary.BigEndian.PutUint64(key[8:], sequence)
+
+	return key, bucket.Put(key, value)
+}
+
+// promoteScheduled moves all due values from the scheduled bucket to the back of the bucket with the
+// provided name. The due time of the next scheduled value is returned, or the zero time if there is
+// none. The number of moved values is returned as well. Delivery attempts of the scheduled values are
+// carried along. If the bucket holds capacity values, the remaining due values stay scheduled until
+// there's space again. A capacity less than one means no limit.
+func promoteScheduled(tx *bolt.Tx, name []byte, scheme KeyScheme, capacity int, now time.Time) (time.Time, int, error) {
+	bucket := tx.Bucket(scheduledName(name))
+	if bucket == nil {
+		return time.Time{}, 0, nil
+	}
+
+	size := 0
+	if capacity > 0 {
+		size = bucketKeyCountUpTo(tx, name, capacity)
+	}
+
+	count := 0
+	cursor := bucket.Cursor()
+	for key, value := cursor.First(); key != nil; key, value = cursor.First() {
+		due := scheduledDue(key)
+		if due.After(now) || (capacity > 0 && size+count >= capacity) {
+			return due, count, nil
+		}
+
+		newKey, err := push(tx, name, PositionBack, value, scheme)
+		if err != nil {
+			return time.Time{}, count, err
+		}
+		attempts, err := takeAttempts(tx, scheduledName(name), key)
+		if err != nil {
+			return time.Time{}, count, err
+		}
+		if attempts > 0 {
+			if err := putAttempts(tx, name, newKey, attempts); err != nil {
+				return time.Time{}, count, err
+			}
+		}
+		if err := cursor.Delete(); err != nil {
+			return time.Time{}, count, err
+		}
+		count++
+	}
+
+	return time.Time{}, count, nil
+}
+
+// peekScheduled returns the first scheduled key and value of the bucket with the provided name, if it's
+// due.
+func peekScheduled(tx *bolt.Tx, name []byte, now time.Time) ([]byte, []byte) {
+	bucket := tx.Bucket(scheduledName(name))
+	if bucket == nil {
+		return nil, nil
+	}
+
+	key, value := bucket.Cursor().First()
+	if key == nil || scheduledDue(key).After(now) {
+		return nil, nil
+	}
+	return key, value
+}
+
+// countScheduled returns the number of scheduled values and the number of due values of the bucket
+// with the provided name.
+func countScheduled(tx *bolt.Tx, name []byte, now time.Time) (int, int) {
+	bucket := tx.Bucket(scheduledName(name))
+	if bucket == nil {
+		return 0, 0
+	}
+
+	total, due := 0, 0
+	cursor := bucket.Cursor()
+	for key, _ := cursor.First(); key != nil; key, _ = cursor.Next() {
+		total++
+		if !scheduledDue(key).After(now) {
+			due++
+		}
+	}
+	return total, due
+}
+
+func scheduledDue(key []byte) time.Time {
+	return time.Unix(0, int64(binary.BigEndian.Uint64(key[:8])))
+}
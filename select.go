@@ -0,0 +1,197 @@
+package boltx
+
+import (
+	"context"
+	"encoding"
+	"errors"
+	"math/rand"
+	"time"
+
+	"github.com/boltdb/bolt"
+)
+
+// ErrMixedDBs is returned by Select if the provided sources don't share the same db.
+var ErrMixedDBs = errors.New("sources of different dbs")
+
+// Source defines a bucket that can be selected from. It's implemented by Queue, PriorityQueue and the
+// ends of a Deque returned by Front and Back.
+type Source interface {
+	selectSource() source
+}
+
+// source describes how an element is taken from a bucket.
+type source struct {
+	db       *bolt.DB
+	session  *Session
+	name     []byte
+	position *Position
+	weight   int
+	ready    readyFunc
+	expire   expireFunc
+	envelope bool
+	take     func(tx *bolt.Tx, key []byte) error
+}
+
+func (q *Queue) selectSource() source {
+	return source{
+		db:       q.db,
+		session:  q.session,
+		name:     q.name,
+		position: PositionFront,
+		weight:   1,
+		ready:    q.ready,
+		expire:   q.expiry(),
+		envelope: q.envelope,
+		take: func(tx *bolt.Tx, key []byte) error {
+			_, err := takeAttempts(tx, q.name, key)
+			return err
+		},
+	}
+}
+
+func (pq *PriorityQueue) selectSource() source {
+	return source{db: pq.db, session: pq.session, name: pq.name, position: PositionFront, weight: 1}
+}
+
+type dequeEnd struct {
+	deque    *Deque
+	position *Position
+}
+
+// Front returns the front of the deque as a source for Select.
+func (d *Deque) Front() Source {
+	return dequeEnd{deque: d, position: PositionFront}
+}
+
+// Back returns the back of the deque as a source for Select.
+func (d *Deque) Back() Source {
+	return dequeEnd{deque: d, position: PositionBack}
+}
+
+func (de dequeEnd) selectSource() source {
+	return source{
+		db:       de.deque.db,
+		session:  de.deque.session,
+		name:     de.deque.name,
+		position: de.position,
+		weight:   1,
+		expire:   de.deque.expiry(),
+		envelope: de.deque.envelope,
+	}
+}
+
+type weightedSource struct {
+	source Source
+	weight int
+}
+
+// Weighted returns the provided source with the provided weight. If several sources hold elements,
+// Select prefers a source in proportion to its weight, so a busy source cannot starve the others.
+// The default weight is one.
+func Weighted(s Source, weight int) Source {
+	return weightedSource{source: s, weight: weight}
+}
+
+func (ws weightedSource) selectSource() source {
+	s := ws.source.selectSource()
+	s.weight = ws.weight
+	if s.weight < 1 {
+		s.weight = 1
+	}
+	return s
+}
+
+// Select gets the value from the first of the provided sources that holds an element, unmarshals it
+// into the provided model and removes it. The index of that source is returned. If all sources are empty,
+// the call blocks until an element is enqueued to one of them or the provided context is done. If
+// several sources hold elements, one of them is chosen randomly in proportion to their weights. All
+// sources have to share the same db.
+func Select(ctx context.Context, model encoding.BinaryUnmarshaler, sources ...Source) (int, error) {
+	if len(sources) == 0 {
+		return -1, errors.New("no sources to select from")
+	}
+
+	selected := make([]source, len(sources))
+	notifiers := make([]*notifier, len(sources))
+	for index, s := range sources {
+		selected[index] = s.selectSource()
+		if selected[index].db != selected[0].db {
+			return -1, ErrMixedDBs
+		}
+		notifiers[index] = selected[index].session.hub.notifier(selected[index].name)
+	}
+	session := selected[0].session
+
+	result := -1
+	attempt := func(tx *bolt.Tx) (bool, time.Time, error) {
+		next := time.Time{}
+		for _, s := range selected {
+			if s.ready == nil {
+				continue
+			}
+			due, err := s.ready(tx)
+			if err != nil {
+				return false, time.Time{}, err
+			}
+			if !due.IsZero() && (next.IsZero() || due.Before(next)) {
+				next = due
+			}
+		}
+
+		now := time.Now()
+		for _, index := range weightedOrder(selected) {
+			s := selected[index]
+			key, value, dropped, err := popLive(tx, s.name, s.position, now, s.expire)
+			if err != nil {
+				return false, time.Time{}, err
+			}
+			if dropped > 0 {
+				s.session.signalFreeOnCommit(tx, s.name)
+			}
+			if key == nil {
+				continue
+			}
+			if s.take != nil {
+				if err := s.take(tx, key); err != nil {
+					return false, time.Time{}, err
+				}
+			}
+			if err := unmarshalElement(model, value, s.envelope); err != nil {
+				return false, time.Time{}, unmarshalError(err, s.name, key)
+			}
+			s.session.signalFreeOnCommit(tx, s.name)
+			result = index
+			return true, time.Time{}, nil
+		}
+		return false, next, nil
+	}
+
+	if err := session.updateOrWait(ctx, nextUpdates(notifiers...), attempt); err != nil {
+		return -1, err
+	}
+	return result, nil
+}
+
+// weightedOrder returns the indices of the provided sources in a random order. A source with a higher
+// weight is more likely to come first.
+func weightedOrder(sources []source) []int {
+	remaining, total := make([]int, len(sources)), 0
+	for index, s := range sources {
+		remaining[index] = index
+		total += s.weight
+	}
+
+	order := make([]int, 0, len(sources))
+	for len(remaining) > 0 {
+		n := rand.Intn(total)
+		for position, index := range remaining {
+			if n -= sources[index].weight; n < 0 {
+				order = append(order, index)
+				remaining = append(remaining[:position], remaining[position+1:]...)
+				total -= sources[index].weight
+				break
+			}
+		}
+	}
+	return order
+}
@@ -0,0 +1,101 @@
+package boltx_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/simia-tech/boltx"
+)
+
+func TestSelect(t *testing.T) {
+	db, tearDown := setUpTestDB(t)
+	defer tearDown()
+
+	high := boltx.NewQueue(db, []byte("high"))
+	low := boltx.NewQueue(db, []byte("low"))
+	require.NoError(t, low.EnqueueModel(&model{field: "test"}))
+
+	value := &model{}
+	index, err := boltx.Select(context.Background(), value, high, low)
+	require.NoError(t, err)
+	assert.Equal(t, 1, index)
+	assert.Equal(t, &model{field: "test"}, value)
+	assert.Equal(t, 0, low.Size())
+}
+
+func TestSelectOnEmpty(t *testing.T) {
+	db, tearDown := setUpTestDB(t)
+	defer tearDown()
+
+	queue := boltx.NewQueue(db, []byte("queue"))
+	deque := boltx.NewDeque(db, []byte("deque"))
+	priorityQueue := boltx.NewPriorityQueue(db, []byte("priority"))
+
+	type result struct {
+		index int
+		value *model
+	}
+	results := make(chan result)
+	go func() {
+		for count := 0; count < 2; count++ {
+			value := &model{}
+			index, err := boltx.Select(context.Background(), value, queue, deque.Back(), priorityQueue)
+			require.NoError(t, err)
+			results <- result{index: index, value: value}
+		}
+	}()
+
+	time.Sleep(20 * time.Millisecond)
+	require.NoError(t, deque.EnqueueModelBack(&model{field: "deque"}))
+	assert.Equal(t, result{index: 1, value: &model{field: "deque"}}, <-results)
+
+	time.Sleep(20 * time.Millisecond)
+	require.NoError(t, priorityQueue.EnqueueModel(1, &model{field: "priority"}))
+	assert.Equal(t, result{index: 2, value: &model{field: "priority"}}, <-results)
+}
+
+func TestSelectWithContext(t *testing.T) {
+	db, tearDown := setUpTestDB(t)
+	defer tearDown()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+
+	_, err := boltx.Select(ctx, &model{}, boltx.NewQueue(db, []byte("one")), boltx.NewQueue(db, []byte("two")))
+	assert.Equal(t, context.DeadlineExceeded, err)
+}
+
+func TestSelectWeighted(t *testing.T) {
+	db, tearDown := setUpTestDB(t)
+	defer tearDown()
+
+	high := boltx.NewQueue(db, []byte("high"))
+	low := boltx.NewQueue(db, []byte("low"))
+	for count := 0; count < 100; count++ {
+		require.NoError(t, high.EnqueueModel(&model{field: "high"}))
+		require.NoError(t, low.EnqueueModel(&model{field: "low"}))
+	}
+
+	counts := make([]int, 2)
+	for count := 0; count < 100; count++ {
+		index, err := boltx.Select(context.Background(), &model{}, boltx.Weighted(high, 3), low)
+		require.NoError(t, err)
+		counts[index]++
+	}
+	assert.True(t, counts[0] > counts[1])
+	assert.True(t, counts[1] > 0)
+}
+
+func TestSelectFromDifferentDBs(t *testing.T) {
+	db, tearDown := setUpTestDB(t)
+	defer tearDown()
+	otherDB, otherTearDown := setUpTestDB(t)
+	defer otherTearDown()
+
+	_, err := boltx.Select(context.Background(), &model{}, boltx.NewQueue(db, []byte("test")), boltx.NewQueue(otherDB, []byte("test")))
+	assert.Equal(t, boltx.ErrMixedDBs, err)
+}
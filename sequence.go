@@ -1,20 +1,27 @@
 package boltx
 
 import (
+	"context"
 	"encoding"
+	"errors"
 	"fmt"
-	"math"
-	"math/big"
+	"time"
 
 	"github.com/boltdb/bolt"
 )
 
 var (
+	// ErrEmpty is returned if a value should be taken from an empty queue or deque.
+	ErrEmpty = errors.New("queue is empty")
+
+	// ErrFull is returned if a value should be put into a queue or deque that reached its capacity.
+	ErrFull = errors.New("queue is full")
+
 	// DefaultUint64QueueKey defines the default key for a queue with uint64 keys.
-	DefaultUint64QueueKey = []byte{0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00}
+	DefaultUint64QueueKey = Uint64QueueKeyScheme.DefaultKey()
 
 	// DefaultUint64DequeKey defines the default key for a deque (double-ended queue) with uint64 keys.
-	DefaultUint64DequeKey = big.NewInt(0).SetUint64(math.MaxUint64 / 2).Bytes()
+	DefaultUint64DequeKey = Uint64DequeKeyScheme.DefaultKey()
 
 	// PositionFront specifies the front of a queue or deque.
 	PositionFront = &Position{delta: -1, fn: func(cursor *bolt.Cursor) ([]byte, []byte) {
@@ -34,87 +41,330 @@ type Position struct {
 }
 
 // Push inserts the provided value at the provided position in the provided bucket. If the
-// bucket is empty, the provided defaultKey is used.
+// bucket is empty, the provided defaultKey is used. All further keys have the same width as the
+// default key.
 func Push(tx *bolt.Tx, name []byte, position *Position, value, defaultKey []byte) error {
+	_, err := push(tx, name, position, value, defaultKeyScheme(defaultKey))
+	return err
+}
+
+// PushWithKeyScheme inserts the provided value at the provided position in the provided bucket. The
+// key is generated by the provided key scheme.
+func PushWithKeyScheme(tx *bolt.Tx, name []byte, position *Position, value []byte, scheme KeyScheme) error {
+	_, err := push(tx, name, position, value, scheme)
+	return err
+}
+
+// push inserts the provided value like PushWithKeyScheme and returns the key of the inserted value.
+func push(tx *bolt.Tx, name []byte, position *Position, value []byte, scheme KeyScheme) ([]byte, error) {
 	bucket, err := tx.CreateBucketIfNotExists(name)
 	if err != nil {
-		return err
+		return nil, err
 	}
 
 	cursor := bucket.Cursor()
 	key, _ := position.fn(cursor)
 	if key == nil {
-		key = defaultKey
-	} else {
-		key = addToKey(key, position.delta)
+		key = scheme.DefaultKey()
+	} else if key, err = scheme.Next(key, position.delta); err != nil {
+		return nil, err
 	}
 
 	if err := bucket.Put(key, value); err != nil {
-		return err
+		return nil, err
 	}
 
-	return nil
+	return key, nil
 }
 
 // Pop removes and returns the value at the provided position in the provided bucket. If the bucket is empty,
 // nil is returned.
 func Pop(tx *bolt.Tx, name []byte, position *Position) []byte {
+	_, value := pop(tx, name, position)
+	return value
+}
+
+// pop behaves like Pop, but returns the key of the removed value as well.
+func pop(tx *bolt.Tx, name []byte, position *Position) ([]byte, []byte) {
 	bucket := tx.Bucket(name)
 	if bucket == nil {
-		return nil
+		return nil, nil
 	}
 
 	cursor := bucket.Cursor()
-	_, value := position.fn(cursor)
+	key, value := position.fn(cursor)
+	if key == nil {
+		return nil, nil
+	}
+	key = append([]byte{}, key...)
 	_ = cursor.Delete()
+	return key, value
+}
+
+// Peek returns the value at the provided position in the provided bucket without removing it. If the
+// bucket is empty, nil is returned. The value is only valid during the lifetime of the transaction.
+func Peek(tx *bolt.Tx, name []byte, position *Position) []byte {
+	bucket := tx.Bucket(name)
+	if bucket == nil {
+		return nil
+	}
+
+	_, value := position.fn(bucket.Cursor())
 	return value
 }
 
-func addToKey(key []byte, value int64) []byte {
-	return big.NewInt(0).Add(big.NewInt(0).SetBytes(key), big.NewInt(value)).Bytes()
+// TryPop behaves like Pop, but returns ErrEmpty if the bucket is empty.
+func TryPop(tx *bolt.Tx, name []byte, position *Position) ([]byte, error) {
+	value := Pop(tx, name, position)
+	if value == nil {
+		return nil, ErrEmpty
+	}
+	return value, nil
 }
 
 // PopOrWait tries to pop a value from the provided bucket at the provided position. If the bucket is empty,
-// the provided transaction is stored in the provided session and the function blocks until a value is inserted
-// into the bucket. Insert-transactions should be started with session.Update.
-func PopOrWait(tx *bolt.Tx, name []byte, position *Position, session *Session) []byte {
-	session.updateSignal.L.Lock()
-	session.tx = tx
-	var value []byte
-	for value = Pop(tx, name, position); value == nil; value = Pop(tx, name, position) {
-		session.updateSignal.Wait()
-	}
-	session.tx = nil
-	session.updateSignal.L.Unlock()
-	return value
+// the function blocks until a value is inserted into the bucket. The waiting happens outside of any
+// transaction. Insert-transactions have to signal the update through a session of the same db - e.g. with
+// PushAndSignal.
+func PopOrWait(session *Session, name []byte, position *Position) ([]byte, error) {
+	return PopOrWaitContext(context.Background(), session, name, position)
 }
 
-// PushAndSignal pushes the the provided value at the provided position in the provided bucket. Afterwards
-// an update is siganled through the provided session.
-func PushAndSignal(tx *bolt.Tx, name []byte, position *Position, value, defaultKey []byte, session *Session) error {
-	session.updateSignal.L.Lock()
-	defer session.updateSignal.L.Unlock()
+// PopOrWaitContext behaves like PopOrWait, but stops waiting if the provided context is done. In that case,
+// the context's error is returned and the bucket is left untouched.
+func PopOrWaitContext(ctx context.Context, session *Session, name []byte, position *Position) ([]byte, error) {
+	value := []byte(nil)
+	err := popOrWait(ctx, session, name, position, 1, true, nil, nil, func(tx *bolt.Tx, keys, values [][]byte) error {
+		value = append([]byte{}, values[0]...)
+		return nil
+	})
+	return value, err
+}
+
+// readyFunc defines a function that makes pending values available in a bucket before they're
+// popped. It returns the time when the next pending value becomes available or the zero time if
+// there is none.
+type readyFunc func(tx *bolt.Tx) (time.Time, error)
+
+// takeFunc defines a function that receives popped values together with their keys. It's called
+// within the popping transaction.
+type takeFunc func(tx *bolt.Tx, keys, values [][]byte) error
+
+// popOrWait pops up to n values from the provided position and passes them to the provided take
+// function. If n is less than one, all values are popped. If the bucket is empty, ErrEmpty is returned
+// or - if wait is true - the function waits outside of the transaction for an update and tries again.
+// If a ready function is provided, it's called before every attempt and the wait ends at the latest
+// when the next value becomes available. Expired values are skipped and passed to the provided expire
+// function, if there is one.
+func popOrWait(
+	ctx context.Context,
+	session *Session,
+	name []byte,
+	position *Position,
+	n int,
+	wait bool,
+	ready readyFunc,
+	expire expireFunc,
+	take takeFunc,
+) error {
+	attempt := func(tx *bolt.Tx) (bool, time.Time, error) {
+		next := time.Time{}
+		if ready != nil {
+			var err error
+			if next, err = ready(tx); err != nil {
+				return false, time.Time{}, err
+			}
+		}
+
+		now, dropped := time.Now(), 0
+		keys, values := [][]byte{}, [][]byte{}
+		for n < 1 || len(values) < n {
+			key, value, skipped, err := popLive(tx, name, position, now, expire)
+			if err != nil {
+				return false, time.Time{}, err
+			}
+			dropped += skipped
+			if key == nil {
+				break
+			}
+			keys, values = append(keys, key), append(values, value)
+		}
+		if dropped+len(values) > 0 {
+			session.signalFreeOnCommit(tx, name)
+		}
+		if len(values) == 0 {
+			return false, next, nil
+		}
+
+		if err := take(tx, keys, values); err != nil {
+			return false, time.Time{}, err
+		}
+		return true, time.Time{}, nil
+	}
+
+	if !wait {
+		done := false
+		if err := session.Update(func(tx *bolt.Tx) (err error) {
+			done, _, err = attempt(tx)
+			return
+		}); err != nil {
+			return err
+		}
+		if !done {
+			return ErrEmpty
+		}
+		return nil
+	}
+
+	return session.updateOrWait(ctx, nextUpdates(session.hub.notifier(name)), attempt)
+}
+
+// PushOrWaitContext pushes the provided value at the provided position in the provided bucket. If the bucket
+// already holds capacity values, the function waits outside of the transaction until a value is removed
+// from the bucket or the provided context is done. A capacity less than one means no limit. Afterwards an
+// update is signaled through the provided session.
+func PushOrWaitContext(
+	ctx context.Context,
+	session *Session,
+	name []byte,
+	position *Position,
+	value, defaultKey []byte,
+	capacity int,
+) error {
+	return pushOrWait(ctx, session, name, position, [][]byte{value}, defaultKeyScheme(defaultKey), capacity, true)
+}
+
+// pushOrWait pushes all the provided values at once. If the bucket hasn't enough space left, ErrFull is
+// returned or - if wait is true - the function waits until enough values have been removed.
+func pushOrWait(
+	ctx context.Context,
+	session *Session,
+	name []byte,
+	position *Position,
+	values [][]byte,
+	scheme KeyScheme,
+	capacity int,
+	wait bool,
+) error {
+	if capacity > 0 && len(values) > capacity {
+		return ErrFull
+	}
+
+	attempt := func(tx *bolt.Tx) (bool, time.Time, error) {
+		err := tryPush(tx, name, position, values, scheme, capacity, session)
+		if err == ErrFull && wait {
+			return false, time.Time{}, nil
+		}
+		return err == nil, time.Time{}, err
+	}
+
+	return session.updateOrWait(ctx, nextFrees(session.hub.notifier(name)), attempt)
+}
+
+// tryPush pushes all the provided values at once in the provided transaction. If the bucket hasn't enough
+// space left, ErrFull is returned. An update is broadcasted after the transaction has been committed.
+func tryPush(
+	tx *bolt.Tx,
+	name []byte,
+	position *Position,
+	values [][]byte,
+	scheme KeyScheme,
+	capacity int,
+	session *Session,
+) error {
+	if capacity > 0 && bucketKeyCountUpTo(tx, name, capacity)+len(values) > capacity {
+		return ErrFull
+	}
 
+	for _, value := range values {
+		if _, err := push(tx, name, position, value, scheme); err != nil {
+			return err
+		}
+	}
+	session.broadcastOnCommit(tx, name)
+	return nil
+}
+
+func bucketKeyCount(tx *bolt.Tx, name []byte) int {
+	return bucketKeyCountUpTo(tx, name, -1)
+}
+
+// bucketKeyCountUpTo counts the keys in the bucket with the provided name, but stops at the provided
+// limit. This way, the capacity of a bounded bucket can be checked at the cost of the capacity rather
+// than the size of the bucket. A negative limit means no limit.
+func bucketKeyCountUpTo(tx *bolt.Tx, name []byte, limit int) int {
+	bucket := tx.Bucket(name)
+	if bucket == nil {
+		return 0
+	}
+
+	count := 0
+	cursor := bucket.Cursor()
+	for key, _ := cursor.First(); key != nil && count != limit; key, _ = cursor.Next() {
+		count++
+	}
+	return count
+}
+
+// PushAndSignal pushes the the provided value at the provided position in the provided bucket. Once the
+// transaction is committed, an update is broadcasted through the provided session. If it's rolled back,
+// no consumer is woken up.
+func PushAndSignal(tx *bolt.Tx, name []byte, position *Position, value, defaultKey []byte, session *Session) error {
 	if err := Push(tx, name, position, value, defaultKey); err != nil {
 		return err
 	}
-	session.updateSignal.Signal()
+	session.broadcastOnCommit(tx, name)
 
 	return nil
 }
 
-// PopModelOrWait behaves like PopOrWair, but handels the model unmarshaling.
-func PopModelOrWait(
-	tx *bolt.Tx,
+// PopModelOrWait behaves like PopOrWait, but handels the model unmarshaling.
+func PopModelOrWait(session *Session, name []byte, position *Position, model encoding.BinaryUnmarshaler) error {
+	return PopModelOrWaitContext(context.Background(), session, name, position, model)
+}
+
+// PopModelOrWaitContext behaves like PopOrWaitContext, but handels the model unmarshaling.
+func PopModelOrWaitContext(
+	ctx context.Context,
+	session *Session,
 	name []byte,
 	position *Position,
 	model encoding.BinaryUnmarshaler,
-	session *Session,
 ) error {
-	value := PopOrWait(tx, name, position, session)
+	_, err := popModelsOrWait(ctx, session, name, position, 1, true, factoryOf(model), nil)
+	return err
+}
 
-	if err := model.UnmarshalBinary(value); err != nil {
-		return fmt.Errorf("unmarshaling failed: %v", err)
+// TryPopModel behaves like TryPop, but handels the model unmarshaling.
+func TryPopModel(tx *bolt.Tx, name []byte, position *Position, model encoding.BinaryUnmarshaler) error {
+	key, value := pop(tx, name, position)
+	if key == nil {
+		return ErrEmpty
+	}
+
+	if err := unmarshalElement(model, value, false); err != nil {
+		return unmarshalError(err, name, key)
+	}
+
+	return nil
+}
+
+// PeekModel behaves like Peek, but handels the model unmarshaling. If the bucket is empty, ErrEmpty is
+// returned.
+func PeekModel(tx *bolt.Tx, name []byte, position *Position, model encoding.BinaryUnmarshaler) error {
+	return peekModel(tx, name, position, model, false)
+}
+
+// peekModel behaves like PeekModel. If the envelope flag is set, expired values are skipped and only the
+// payload is unmarshaled.
+func peekModel(tx *bolt.Tx, name []byte, position *Position, model encoding.BinaryUnmarshaler, envelope bool) error {
+	key, value := peekLive(tx, name, position, time.Now(), envelope)
+	if key == nil {
+		return ErrEmpty
+	}
+
+	if err := unmarshalElement(model, value, envelope); err != nil {
+		return unmarshalError(err, name, key)
 	}
 
 	return nil
@@ -140,3 +390,95 @@ func PushModelAndSignal(
 
 	return nil
 }
+
+// PopModelsOrWaitContext behaves like PopModelOrWaitContext, but pops up to n values at once. If n is
+// less than one, all values are popped. The models are created using the provided factory.
+func PopModelsOrWaitContext(
+	ctx context.Context,
+	session *Session,
+	name []byte,
+	position *Position,
+	n int,
+	factory ModelFactory,
+) ([]encoding.BinaryUnmarshaler, error) {
+	return popModelsOrWait(ctx, session, name, position, n, true, factory, nil)
+}
+
+// popModelsOrWait pops values like popOrWait and unmarshals them into models created by the provided
+// factory.
+func popModelsOrWait(
+	ctx context.Context,
+	session *Session,
+	name []byte,
+	position *Position,
+	n int,
+	wait bool,
+	factory ModelFactory,
+	ready readyFunc,
+) ([]encoding.BinaryUnmarshaler, error) {
+	models := []encoding.BinaryUnmarshaler(nil)
+	err := popOrWait(ctx, session, name, position, n, wait, ready, nil, func(tx *bolt.Tx, keys, values [][]byte) (err error) {
+		models, err = unmarshalModels(name, keys, values, factory, false)
+		return
+	})
+	return models, err
+}
+
+// unmarshalModels unmarshals the provided values of the bucket with the provided name into models created
+// by the provided factory.
+func unmarshalModels(name []byte, keys, values [][]byte, factory ModelFactory, envelope bool) ([]encoding.BinaryUnmarshaler, error) {
+	models := make([]encoding.BinaryUnmarshaler, len(values))
+	for index, value := range values {
+		models[index] = factory()
+		if err := unmarshalElement(models[index], value, envelope); err != nil {
+			return nil, unmarshalError(err, name, keys[index])
+		}
+	}
+	return models, nil
+}
+
+// PushModelsAndSignal behaves like PushModelAndSignal, but pushes all the provided models one after
+// another. All waiting consumers are woken up once after the transaction is committed.
+func PushModelsAndSignal(
+	tx *bolt.Tx,
+	name []byte,
+	position *Position,
+	models []encoding.BinaryMarshaler,
+	defaultKey []byte,
+	session *Session,
+) error {
+	for _, model := range models {
+		value, err := model.MarshalBinary()
+		if err != nil {
+			return fmt.Errorf("marshaling failed: %v", err)
+		}
+		if err := Push(tx, name, position, value, defaultKey); err != nil {
+			return err
+		}
+	}
+	session.broadcastOnCommit(tx, name)
+
+	return nil
+}
+
+// pushModelsOrWait marshals the provided models and pushes them like pushOrWait.
+func pushModelsOrWait(
+	ctx context.Context,
+	session *Session,
+	name []byte,
+	position *Position,
+	models []encoding.BinaryMarshaler,
+	scheme KeyScheme,
+	capacity int,
+	wait bool,
+) error {
+	values := make([][]byte, len(models))
+	for index, model := range models {
+		value, err := model.MarshalBinary()
+		if err != nil {
+			return fmt.Errorf("marshaling failed: %v", err)
+		}
+		values[index] = value
+	}
+	return pushOrWait(ctx, session, name, position, values, scheme, capacity, wait)
+}
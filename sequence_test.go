@@ -1,6 +1,7 @@
 package boltx_test
 
 import (
+	"context"
 	"testing"
 	"time"
 
@@ -50,10 +51,9 @@ func TestPushAndPopConcurrently(t *testing.T) {
 
 	result := make(chan string)
 	go func() {
-		require.NoError(t, db.Update(func(tx *bolt.Tx) error {
-			result <- string(boltx.PopOrWait(tx, name, boltx.PositionFront, session))
-			return nil
-		}))
+		value, err := boltx.PopOrWait(session, name, boltx.PositionFront)
+		require.NoError(t, err)
+		result <- string(value)
 	}()
 
 	time.Sleep(10 * time.Millisecond)
@@ -70,9 +70,125 @@ func TestPushAndPopConcurrently(t *testing.T) {
 
 	require.NoError(t, session.Update(func(tx *bolt.Tx) error {
 		require.NoError(t, boltx.PushAndSignal(tx, name, boltx.PositionBack, []byte("test"), boltx.DefaultUint64QueueKey, session))
-		assert.Equal(t, "test", string(boltx.PopOrWait(tx, name, boltx.PositionFront, session)))
 		return nil
 	}))
+	value, err := boltx.PopOrWait(session, name, boltx.PositionFront)
+	require.NoError(t, err)
+	assert.Equal(t, "test", string(value))
 
 	require.Equal(t, 0, boltx.BucketSize(db, name))
 }
+
+func TestPopOrWaitContext(t *testing.T) {
+	db, tearDown := setUpTestDB(t)
+	defer tearDown()
+
+	name := []byte("test")
+	session := boltx.NewSession(db)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+
+	value, err := boltx.PopOrWaitContext(ctx, session, name, boltx.PositionFront)
+	assert.Equal(t, context.DeadlineExceeded, err)
+	assert.Nil(t, value)
+
+	require.NoError(t, session.Update(func(tx *bolt.Tx) error {
+		return boltx.PushAndSignal(tx, name, boltx.PositionBack, []byte("test"), boltx.DefaultUint64QueueKey, session)
+	}))
+	value, err = boltx.PopOrWaitContext(context.Background(), session, name, boltx.PositionFront)
+	require.NoError(t, err)
+	assert.Equal(t, "test", string(value))
+}
+
+func TestPopOrWaitDoesNotBlockWriters(t *testing.T) {
+	db, tearDown := setUpTestDB(t)
+	defer tearDown()
+
+	name := []byte("test")
+	session := boltx.NewSession(db)
+
+	result := make(chan string)
+	go func() {
+		value, err := boltx.PopOrWait(session, name, boltx.PositionFront)
+		require.NoError(t, err)
+		result <- string(value)
+	}()
+
+	time.Sleep(10 * time.Millisecond)
+
+	done := make(chan error)
+	go func() {
+		done <- boltx.PutInBucket(db, []byte("other"), []byte("key"), []byte("value"))
+	}()
+
+	select {
+	case err := <-done:
+		require.NoError(t, err)
+	case <-time.After(time.Second):
+		t.Fatal("writer is blocked by waiting consumer")
+	}
+
+	require.NoError(t, session.Update(func(tx *bolt.Tx) error {
+		return boltx.PushAndSignal(tx, name, boltx.PositionBack, []byte("test"), boltx.DefaultUint64QueueKey, session)
+	}))
+	assert.Equal(t, "test", <-result)
+}
+
+func TestTryPop(t *testing.T) {
+	db, tearDown := setUpTestDB(t)
+	defer tearDown()
+
+	name := []byte("test")
+
+	require.NoError(t, db.Update(func(tx *bolt.Tx) error {
+		_, err := boltx.TryPop(tx, name, boltx.PositionFront)
+		assert.Equal(t, boltx.ErrEmpty, err)
+
+		require.NoError(t, boltx.Push(tx, name, boltx.PositionBack, []byte("test"), boltx.DefaultUint64QueueKey))
+
+		value, err := boltx.TryPop(tx, name, boltx.PositionFront)
+		require.NoError(t, err)
+		assert.Equal(t, "test", string(value))
+
+		_, err = boltx.TryPop(tx, name, boltx.PositionFront)
+		assert.Equal(t, boltx.ErrEmpty, err)
+		return nil
+	}))
+}
+
+func TestPeek(t *testing.T) {
+	db, tearDown := setUpTestDB(t)
+	defer tearDown()
+
+	name := []byte("test")
+
+	require.NoError(t, db.Update(func(tx *bolt.Tx) error {
+		assert.Nil(t, boltx.Peek(tx, name, boltx.PositionFront))
+
+		require.NoError(t, boltx.Push(tx, name, boltx.PositionBack, []byte("one"), boltx.DefaultUint64QueueKey))
+		require.NoError(t, boltx.Push(tx, name, boltx.PositionBack, []byte("two"), boltx.DefaultUint64QueueKey))
+
+		assert.Equal(t, "one", string(boltx.Peek(tx, name, boltx.PositionFront)))
+		assert.Equal(t, "two", string(boltx.Peek(tx, name, boltx.PositionBack)))
+		assert.Equal(t, "one", string(boltx.Pop(tx, name, boltx.PositionFront)))
+		return nil
+	}))
+}
+
+func TestPushOrWaitContext(t *testing.T) {
+	db, tearDown := setUpTestDB(t)
+	defer tearDown()
+
+	name := []byte("test")
+	session := boltx.NewSession(db)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+
+	require.NoError(t, boltx.PushOrWaitContext(ctx, session, name, boltx.PositionBack, []byte("one"), boltx.DefaultUint64QueueKey, 1))
+	assert.Equal(t, context.DeadlineExceeded, boltx.PushOrWaitContext(ctx, session, name, boltx.PositionBack, []byte("two"), boltx.DefaultUint64QueueKey, 1))
+	require.NoError(t, boltx.PushOrWaitContext(ctx, session, name, boltx.PositionBack, []byte("two"), boltx.DefaultUint64QueueKey, 0))
+
+	require.Equal(t, 2, boltx.BucketSize(db, name))
+}
@@ -1,31 +1,161 @@
 package boltx
 
 import (
+	"context"
+	"errors"
 	"sync"
+	"time"
 
 	"github.com/boltdb/bolt"
 )
 
-// Session defines a update session. It can be used to re-used transactions that are waiting
-// on an update.
+// ErrClosed is returned by all calls on a closed session - including the ones that have been waiting
+// when the session was closed.
+var ErrClosed = errors.New("session closed")
+
+// Session defines a update session. It notifies callers that are waiting for an update of a bucket.
+// The waiting happens outside of any transaction, so other writers can proceed in the meantime. All
+// sessions on the same db share their notifications, so a signal sent through one session wakes up the
+// callers waiting on any other session for the same bucket. A session is safe to use with multiple
+// goroutines.
 type Session struct {
-	db           *bolt.DB
-	tx           *bolt.Tx
-	updateSignal *sync.Cond
+	db        *bolt.DB
+	hub       *hub
+	closed    chan struct{}
+	closeOnce sync.Once
 }
 
 // NewSession returns a new initialized session.
 func NewSession(db *bolt.DB) *Session {
 	return &Session{
-		db:           db,
-		updateSignal: sync.NewCond(&sync.Mutex{}),
+		db:     db,
+		hub:    acquireHub(db),
+		closed: make(chan struct{}),
 	}
 }
 
-// Update starts an update transaction on the db. If a transaction is set, it's re-used.
+// Update starts an update transaction on the db. If the session is closed, ErrClosed is returned.
 func (s *Session) Update(fn func(tx *bolt.Tx) error) error {
-	if s.tx == nil {
-		return s.db.Update(fn)
+	if s.isClosed() {
+		return ErrClosed
+	}
+	return s.db.Update(fn)
+}
+
+// Broadcast wakes up all callers that are waiting for an update of the bucket with the provided name.
+// Since every woken caller tries again, it's sufficient to call it once after pushing multiple values.
+func (s *Session) Broadcast(name []byte) {
+	s.hub.notifier(name).signalUpdate()
+}
+
+// Close closes the session and wakes up all callers that are waiting through it. These calls return
+// ErrClosed. Sessions on the same db are not affected. The notifications of a db are shared as long as
+// one of its sessions is open, so sessions should be closed once they're not needed anymore.
+func (s *Session) Close() error {
+	s.closeOnce.Do(func() {
+		close(s.closed)
+		releaseHub(s.db)
+	})
+	return nil
+}
+
+func (s *Session) isClosed() bool {
+	select {
+	case <-s.closed:
+		return true
+	default:
+		return false
+	}
+}
+
+// broadcastOnCommit behaves like Broadcast, but waits until the provided transaction is committed. If
+// the transaction is rolled back, nobody is woken up.
+func (s *Session) broadcastOnCommit(tx *bolt.Tx, name []byte) {
+	tx.OnCommit(func() {
+		s.Broadcast(name)
+	})
+}
+
+// signalFreeOnCommit wakes up all callers that are waiting for a removal from the bucket with the
+// provided name, once the provided transaction is committed.
+func (s *Session) signalFreeOnCommit(tx *bolt.Tx, name []byte) {
+	tx.OnCommit(func() {
+		s.hub.notifier(name).signalFree()
+	})
+}
+
+// attemptFunc defines a function that tries to make progress in the provided transaction. It returns
+// false if it had to give up, and the time when it should be tried again at the latest. The zero time
+// means no limit.
+type attemptFunc func(tx *bolt.Tx) (bool, time.Time, error)
+
+// updateOrWait runs the provided attempt function in an update transaction. If it had to give up, the
+// transaction is committed anyway and the function is retried once one of the channels returned by next
+// is closed, the time returned by the attempt is reached or the provided context is done. The channels
+// are obtained before the transaction starts, so no signal can be missed.
+func (s *Session) updateOrWait(ctx context.Context, next signalsFunc, attempt attemptFunc) error {
+	for {
+		signals := next()
+
+		done, retry := false, time.Time{}
+		if err := s.Update(func(tx *bolt.Tx) (err error) {
+			done, retry, err = attempt(tx)
+			return
+		}); err != nil {
+			return err
+		}
+		if done {
+			return nil
+		}
+
+		if err := s.wait(ctx, signals, retry); err != nil {
+			return err
+		}
+	}
+}
+
+// wait blocks until one of the provided channels is closed, the provided time is reached, the provided
+// context is done or the session is closed. In the latter cases, the context's error or ErrClosed is
+// returned.
+func (s *Session) wait(ctx context.Context, signals []<-chan struct{}, until time.Time) error {
+	signal := signals[0]
+	if len(signals) > 1 {
+		merged, stop := merge(signals)
+		defer stop()
+		signal = merged
+	}
+
+	timeout := (<-chan time.Time)(nil)
+	if !until.IsZero() {
+		timer := time.NewTimer(until.Sub(time.Now()))
+		defer timer.Stop()
+		timeout = timer.C
+	}
+
+	select {
+	case <-signal:
+	case <-timeout:
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-s.closed:
+		return ErrClosed
+	}
+	return nil
+}
+
+// merge returns a channel that is closed once one of the provided channels is closed. The returned
+// function releases the resources of the merge and has to be called once the channel isn't needed anymore.
+func merge(signals []<-chan struct{}) (<-chan struct{}, func()) {
+	merged, stop := make(chan struct{}), make(chan struct{})
+	once := sync.Once{}
+	for _, signal := range signals {
+		go func(signal <-chan struct{}) {
+			select {
+			case <-signal:
+				once.Do(func() { close(merged) })
+			case <-stop:
+			}
+		}(signal)
 	}
-	return fn(s.tx)
+	return merged, func() { close(stop) }
 }
@@ -0,0 +1,156 @@
+package boltx_test
+
+import (
+	"encoding"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/boltdb/bolt"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/simia-tech/boltx"
+)
+
+func TestSessionClose(t *testing.T) {
+	db, tearDown := setUpTestDB(t)
+	defer tearDown()
+
+	name := []byte("test")
+	session := boltx.NewSession(db)
+
+	errs := make(chan error)
+	for index := 0; index < 3; index++ {
+		go func() {
+			_, err := boltx.PopOrWait(session, name, boltx.PositionFront)
+			errs <- err
+		}()
+	}
+
+	time.Sleep(10 * time.Millisecond)
+	require.NoError(t, session.Close())
+	require.NoError(t, session.Close())
+
+	for index := 0; index < 3; index++ {
+		assert.Equal(t, boltx.ErrClosed, <-errs)
+	}
+
+	assert.Equal(t, boltx.ErrClosed, session.Update(func(tx *bolt.Tx) error {
+		return nil
+	}))
+
+	other := boltx.NewSession(db)
+	require.NoError(t, other.Update(func(tx *bolt.Tx) error {
+		return boltx.PushAndSignal(tx, name, boltx.PositionBack, []byte("test"), boltx.DefaultUint64QueueKey, other)
+	}))
+	value, err := boltx.PopOrWait(other, name, boltx.PositionFront)
+	require.NoError(t, err)
+	assert.Equal(t, "test", string(value))
+}
+
+func TestSessionCloseReleasesHub(t *testing.T) {
+	db, tearDown := setUpTestDB(t)
+	defer tearDown()
+
+	assert.False(t, boltx.HasHub(db))
+
+	one, two := boltx.NewSession(db), boltx.NewSession(db)
+	assert.True(t, boltx.HasHub(db))
+
+	require.NoError(t, one.Close())
+	require.NoError(t, one.Close())
+	assert.True(t, boltx.HasHub(db))
+
+	require.NoError(t, two.Close())
+	assert.False(t, boltx.HasHub(db))
+
+	queue := boltx.NewQueue(db, []byte("test"))
+	queue.DeadLetterQueue()
+	require.NoError(t, queue.Close())
+	assert.False(t, boltx.HasHub(db))
+}
+
+func TestSessionBroadcast(t *testing.T) {
+	db, tearDown := setUpTestDB(t)
+	defer tearDown()
+
+	name := []byte("test")
+	session := boltx.NewSession(db)
+
+	values := make(chan string)
+	for index := 0; index < 3; index++ {
+		go func() {
+			value, err := boltx.PopOrWait(session, name, boltx.PositionFront)
+			require.NoError(t, err)
+			values <- string(value)
+		}()
+	}
+
+	time.Sleep(10 * time.Millisecond)
+	require.NoError(t, session.Update(func(tx *bolt.Tx) error {
+		return boltx.PushModelsAndSignal(tx, name, boltx.PositionBack, []encoding.BinaryMarshaler{
+			&model{field: "one"}, &model{field: "two"}, &model{field: "three"},
+		}, boltx.DefaultUint64QueueKey, session)
+	}))
+
+	received := []string{<-values, <-values, <-values}
+	assert.ElementsMatch(t, []string{"one", "two", "three"}, received)
+}
+
+func TestSessionConcurrentProducersAndConsumers(t *testing.T) {
+	db, tearDown := setUpTestDB(t)
+	defer tearDown()
+
+	name := []byte("test")
+	producers, consumers, count := 4, 4, 50
+
+	values := make(chan string, producers*count)
+	sessions := make([]*boltx.Session, consumers)
+	wg := sync.WaitGroup{}
+	for index := range sessions {
+		sessions[index] = boltx.NewSession(db)
+		wg.Add(1)
+		go func(session *boltx.Session) {
+			defer wg.Done()
+			for {
+				value, err := boltx.PopOrWait(session, name, boltx.PositionFront)
+				if err == boltx.ErrClosed {
+					return
+				}
+				require.NoError(t, err)
+				values <- string(value)
+			}
+		}(sessions[index])
+	}
+
+	for index := 0; index < producers; index++ {
+		go func(index int) {
+			session := boltx.NewSession(db)
+			for number := 0; number < count; number++ {
+				require.NoError(t, session.Update(func(tx *bolt.Tx) error {
+					value := []byte(fmt.Sprintf("%d-%d", index, number))
+					return boltx.PushAndSignal(tx, name, boltx.PositionBack, value, boltx.DefaultUint64QueueKey, session)
+				}))
+			}
+		}(index)
+	}
+
+	received := map[string]bool{}
+	for len(received) < producers*count {
+		select {
+		case value := <-values:
+			assert.False(t, received[value])
+			received[value] = true
+		case <-time.After(5 * time.Second):
+			t.Fatalf("received only %d of %d values", len(received), producers*count)
+		}
+	}
+	assert.Equal(t, 0, boltx.BucketSize(db, name))
+
+	for _, session := range sessions {
+		require.NoError(t, session.Close())
+	}
+	wg.Wait()
+}
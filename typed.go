@@ -0,0 +1,219 @@
+package boltx
+
+import (
+	"context"
+
+	"github.com/boltdb/bolt"
+)
+
+// Bucket provides type-safe access to the values of type T in a bolt bucket. The values are marshaled
+// and unmarshaled via a pointer to T, so with BinaryCodec, *T has to implement encoding.BinaryMarshaler
+// and encoding.BinaryUnmarshaler.
+type Bucket[T any] struct {
+	bucket *bolt.Bucket
+	codec  Codec
+}
+
+// NewBucket returns a typed view on the provided bucket that uses the provided codec. If the codec is
+// nil, BinaryCodec is used.
+func NewBucket[T any](bucket *bolt.Bucket, codec Codec) *Bucket[T] {
+	if codec == nil {
+		codec = BinaryCodec
+	}
+	return &Bucket[T]{bucket: bucket, codec: codec}
+}
+
+// Put marshals the provided value and stores it under the provided key.
+func (b *Bucket[T]) Put(key []byte, value T) error {
+	return PutValue(b.bucket, key, &value, b.codec)
+}
+
+// Get returns the value that is stored under the provided key. If there is no such value, the zero
+// value and false are returned. A checksum mismatch is returned without the bucket name, since the
+// bolt bucket doesn't know it.
+func (b *Bucket[T]) Get(key []byte) (T, bool, error) {
+	value := *new(T)
+	found, err := GetValue(b.bucket, key, &value, b.codec)
+	return value, found, err
+}
+
+// Delete removes the value that is stored under the provided key.
+func (b *Bucket[T]) Delete(key []byte) error {
+	return b.bucket.Delete(key)
+}
+
+// ForEach iterates over all values in the bucket. The provided function can change the value it gets
+// and return ActionUpdate to store the change. If the function returns ActionReturn, the iteration stops
+// and the current key and value are returned. Like Get, it returns checksum mismatches without the bucket
+// name.
+func (b *Bucket[T]) ForEach(fn func(key []byte, value *T) (Action, error)) ([]byte, T, error) {
+	cursor := b.bucket.Cursor()
+	for key, data := cursor.First(); key != nil; key, data = cursor.Next() {
+		value := *new(T)
+		if err := unmarshalValue(b.codec, nil, key, data, &value); err != nil {
+			return nil, *new(T), err
+		}
+
+		action, err := fn(key, &value)
+		if err != nil {
+			return nil, *new(T), err
+		}
+
+		if ActionDelete&action != 0 {
+			if err := cursor.Delete(); err != nil {
+				return nil, *new(T), err
+			}
+		} else if ActionUpdate&action != 0 {
+			if err := PutValue(b.bucket, key, &value, b.codec); err != nil {
+				return nil, *new(T), err
+			}
+		}
+
+		if ActionReturn&action != 0 {
+			return key, value, nil
+		}
+	}
+	return nil, *new(T), nil
+}
+
+// TypedQueue provides type-safe access to a queue of values of type T. The values are marshaled with the
+// queue's codec via a pointer to T.
+type TypedQueue[T any] struct {
+	queue *Queue
+}
+
+// NewTypedQueue returns a typed view on the provided queue.
+func NewTypedQueue[T any](queue *Queue) *TypedQueue[T] {
+	return &TypedQueue[T]{queue: queue}
+}
+
+// Queue returns the underlying queue.
+func (q *TypedQueue[T]) Queue() *Queue {
+	return q.queue
+}
+
+// Enqueue puts the provided value to the back of the queue. If the queue is full, the call blocks until
+// an element is dequeued.
+func (q *TypedQueue[T]) Enqueue(value T) error {
+	return q.EnqueueContext(context.Background(), value)
+}
+
+// EnqueueContext behaves like Enqueue, but stops waiting for free space if the provided context is done.
+func (q *TypedQueue[T]) EnqueueContext(ctx context.Context, value T) error {
+	return q.queue.EnqueueModelContext(ctx, modelOf(&value, q.queue.codec))
+}
+
+// Dequeue removes the value from the front of the queue and returns it. If the queue is empty the call
+// blocks until an element is enqueued.
+func (q *TypedQueue[T]) Dequeue() (T, error) {
+	return q.DequeueContext(context.Background())
+}
+
+// DequeueContext behaves like Dequeue, but stops waiting for an element if the provided context is done.
+func (q *TypedQueue[T]) DequeueContext(ctx context.Context) (T, error) {
+	value := *new(T)
+	if err := q.queue.DequeueModelContext(ctx, modelOf(&value, q.queue.codec)); err != nil {
+		return *new(T), err
+	}
+	return value, nil
+}
+
+// TryDequeue removes the value from the front of the queue and returns it. If the queue is empty,
+// ErrEmpty is returned immediately.
+func (q *TypedQueue[T]) TryDequeue() (T, error) {
+	value := *new(T)
+	if err := q.queue.TryDequeueModel(modelOf(&value, q.queue.codec)); err != nil {
+		return *new(T), err
+	}
+	return value, nil
+}
+
+// Peek returns the value from the front of the queue without removing it. If the queue is empty,
+// ErrEmpty is returned.
+func (q *TypedQueue[T]) Peek() (T, error) {
+	value := *new(T)
+	if err := q.queue.PeekModel(modelOf(&value, q.queue.codec)); err != nil {
+		return *new(T), err
+	}
+	return value, nil
+}
+
+// TypedDeque provides type-safe access to a deque of values of type T. The values are marshaled with the
+// deque's codec via a pointer to T.
+type TypedDeque[T any] struct {
+	deque *Deque
+}
+
+// NewTypedDeque returns a typed view on the provided deque.
+func NewTypedDeque[T any](deque *Deque) *TypedDeque[T] {
+	return &TypedDeque[T]{deque: deque}
+}
+
+// Deque returns the underlying deque.
+func (d *TypedDeque[T]) Deque() *Deque {
+	return d.deque
+}
+
+// EnqueueFront puts the provided value to the front of the deque. If the deque is full, the call blocks
+// until an element is dequeued.
+func (d *TypedDeque[T]) EnqueueFront(value T) error {
+	return d.deque.EnqueueModelFront(modelOf(&value, d.deque.codec))
+}
+
+// EnqueueBack puts the provided value to the back of the deque. If the deque is full, the call blocks
+// until an element is dequeued.
+func (d *TypedDeque[T]) EnqueueBack(value T) error {
+	return d.deque.EnqueueModelBack(modelOf(&value, d.deque.codec))
+}
+
+// DequeueFront removes the value from the front of the deque and returns it. If the deque is empty the
+// call blocks until an element is enqueued.
+func (d *TypedDeque[T]) DequeueFront() (T, error) {
+	return d.DequeueFrontContext(context.Background())
+}
+
+// DequeueBack removes the value from the back of the deque and returns it. If the deque is empty the
+// call blocks until an element is enqueued.
+func (d *TypedDeque[T]) DequeueBack() (T, error) {
+	return d.DequeueBackContext(context.Background())
+}
+
+// DequeueFrontContext behaves like DequeueFront, but stops waiting for an element if the provided
+// context is done.
+func (d *TypedDeque[T]) DequeueFrontContext(ctx context.Context) (T, error) {
+	value := *new(T)
+	if err := d.deque.DequeueModelFrontContext(ctx, modelOf(&value, d.deque.codec)); err != nil {
+		return *new(T), err
+	}
+	return value, nil
+}
+
+// DequeueBackContext behaves like DequeueBack, but stops waiting for an element if the provided context
+// is done.
+func (d *TypedDeque[T]) DequeueBackContext(ctx context.Context) (T, error) {
+	value := *new(T)
+	if err := d.deque.DequeueModelBackContext(ctx, modelOf(&value, d.deque.codec)); err != nil {
+		return *new(T), err
+	}
+	return value, nil
+}
+
+// PeekFront returns the value from the front of the deque without removing it. If the deque is empty,
+// ErrEmpty is returned.
+func (d *TypedDeque[T]) PeekFront() (T, error) {
+	value := *new(T)
+	if err := d.deque.PeekModelFront(modelOf(&value, d.deque.codec)); err != nil {
+		return *new(T), err
+	}
+	return value, nil
+}
+
+// PeekBack returns the value from the back of the deque without removing it. If the deque is empty,
+// ErrEmpty is returned.
+func (d *TypedDeque[T]) PeekBack() (T, error) {
+	value := *new(T)
+	if err := d.deque.PeekModelBack(modelOf(&value, d.deque.codec)); err != nil {
+		return *new(T), err
+	}
+	return value, nil
+}
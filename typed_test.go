@@ -0,0 +1,123 @@
+package boltx_test
+
+import (
+	"testing"
+
+	"github.com/boltdb/bolt"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/simia-tech/boltx"
+)
+
+func TestBucketPutAndGet(t *testing.T) {
+	db, tearDown := setUpTestDB(t)
+	defer tearDown()
+
+	inTestBucket(t, db, func(bucket *bolt.Bucket) {
+		models := boltx.NewBucket[model](bucket, nil)
+		require.NoError(t, models.Put([]byte("key"), model{field: "test"}))
+
+		value, found, err := models.Get([]byte("key"))
+		require.NoError(t, err)
+		assert.True(t, found)
+		assert.Equal(t, "test", value.field)
+
+		require.NoError(t, models.Delete([]byte("key")))
+		_, found, err = models.Get([]byte("key"))
+		require.NoError(t, err)
+		assert.False(t, found)
+
+		assert.Error(t, models.Put([]byte("key"), model{field: "invalid"}))
+	})
+}
+
+func TestBucketForEach(t *testing.T) {
+	db, tearDown := setUpTestDB(t)
+	defer tearDown()
+
+	inTestBucket(t, db, func(bucket *bolt.Bucket) {
+		items := boltx.NewBucket[item](bucket, boltx.JSONCodec)
+		require.NoError(t, items.Put([]byte("one"), item{Name: "one"}))
+		require.NoError(t, items.Put([]byte("two"), item{Name: "two"}))
+		require.NoError(t, items.Put([]byte("zzz"), item{Name: "three"}))
+
+		_, _, err := items.ForEach(func(key []byte, value *item) (boltx.Action, error) {
+			if value.Name == "three" {
+				return boltx.ActionDelete, nil
+			}
+			value.Count++
+			return boltx.ActionUpdate, nil
+		})
+		require.NoError(t, err)
+
+		key, value, err := items.ForEach(func(key []byte, value *item) (boltx.Action, error) {
+			if value.Name == "two" {
+				return boltx.ActionReturn, nil
+			}
+			return boltx.ActionContinue, nil
+		})
+		require.NoError(t, err)
+		assert.Equal(t, "two", string(key))
+		assert.Equal(t, item{Name: "two", Count: 1}, value)
+
+		_, found, err := items.Get([]byte("zzz"))
+		require.NoError(t, err)
+		assert.False(t, found)
+	})
+}
+
+func TestTypedQueue(t *testing.T) {
+	db, tearDown := setUpTestDB(t)
+	defer tearDown()
+
+	queue := boltx.NewQueue(db, []byte("queue"))
+	queue.SetCodec(boltx.JSONCodec)
+	items := boltx.NewTypedQueue[item](queue)
+
+	require.NoError(t, items.Enqueue(item{Name: "one"}))
+	require.NoError(t, items.Enqueue(item{Name: "two"}))
+
+	value, err := items.Peek()
+	require.NoError(t, err)
+	assert.Equal(t, item{Name: "one"}, value)
+
+	value, err = items.Dequeue()
+	require.NoError(t, err)
+	assert.Equal(t, item{Name: "one"}, value)
+
+	value, err = items.TryDequeue()
+	require.NoError(t, err)
+	assert.Equal(t, item{Name: "two"}, value)
+
+	_, err = items.TryDequeue()
+	assert.Equal(t, boltx.ErrEmpty, err)
+	assert.Equal(t, queue, items.Queue())
+}
+
+func TestTypedDeque(t *testing.T) {
+	db, tearDown := setUpTestDB(t)
+	defer tearDown()
+
+	values := boltx.NewTypedDeque[model](boltx.NewDeque(db, []byte("deque")))
+
+	require.NoError(t, values.EnqueueBack(model{field: "back"}))
+	require.NoError(t, values.EnqueueFront(model{field: "front"}))
+
+	value, err := values.PeekFront()
+	require.NoError(t, err)
+	assert.Equal(t, "front", value.field)
+
+	value, err = values.PeekBack()
+	require.NoError(t, err)
+	assert.Equal(t, "back", value.field)
+
+	value, err = values.DequeueBack()
+	require.NoError(t, err)
+	assert.Equal(t, "back", value.field)
+
+	value, err = values.DequeueFront()
+	require.NoError(t, err)
+	assert.Equal(t, "front", value.field)
+	assert.Equal(t, 0, values.Deque().Size())
+}